@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"os"
+	"path/filepath"
+	"yd_backup/internal/metrics"
+	"yd_backup/internal/models"
+	"yd_backup/internal/notify"
+	"yd_backup/internal/repo/catalog"
+	"yd_backup/internal/repo/credentials"
+	"yd_backup/internal/repo/local"
+	"yd_backup/internal/repo/lock"
+	"yd_backup/internal/repo/nas"
+	"yd_backup/internal/repo/queue"
+	"yd_backup/internal/repo/remote"
+	"yd_backup/internal/repo/state"
+	"yd_backup/internal/secrets"
+	"yd_backup/internal/usecase"
+)
+
+// protectable - назначение, очистка которого пропускает защищенные копии
+type protectable interface {
+	SetProtected(protected func(path string) bool)
+}
+
+// app - общее состояние команд: настройки, логгер и хранилища учетных данных
+type app struct {
+	setting    models.Setting
+	configPath string
+	settingErr error
+	logger     *zap.Logger
+	vault      *secrets.Vault
+	store      *credentials.Store
+	state      *state.Store
+	metrics    *metrics.Registry
+	jsonOutput bool
+}
+
+// checkSetting возвращает ошибку чтения или проверки конфигурации
+func (a *app) checkSetting() error {
+	if a.settingErr != nil {
+		return fmt.Errorf("unable to read config file: %v", a.settingErr)
+	}
+
+	return a.setting.Validate()
+}
+
+// loadSecrets открывает хранилище секретов и подставляет ссылки на секреты
+func (a *app) loadSecrets() error {
+	vault, err := openVault(a.setting)
+
+	if err != nil {
+		return fmt.Errorf("unable to open vault: %v", err)
+	}
+
+	a.vault = vault
+
+	if err := a.setting.ResolveSecrets(secrets.NewResolver(vault).Resolve); err != nil {
+		return fmt.Errorf("unable to resolve secrets: %v", err)
+	}
+
+	a.store = credentials.NewStore(a.setting.GetCredentials())
+	a.store.SetLocker(a.newLocker())
+
+	if err := a.store.Load(); err != nil {
+		a.logger.Error("unable to load credentials", zap.Error(err))
+	}
+
+	return nil
+}
+
+// newService собирает сервис копирования со всеми назначениями
+func (a *app) newService() (*usecase.BackupService, error) {
+	if err := a.loadSecrets(); err != nil {
+		return nil, err
+	}
+
+	if err := createBackupDir(a.setting.Backup.Dir); err != nil {
+		return nil, fmt.Errorf("unable to create backup dir: %v", err)
+	}
+
+	journal := queue.NewJournal(a.setting.Backup.Queue)
+	journal.SetLocker(a.newLocker())
+
+	localBackup := local.NewBackupLocal(a.setting)
+
+	a.metrics = metrics.NewRegistry()
+	usecase.DescribeMetrics(a.metrics)
+
+	destinations, err := createDestinations(a.setting, a.store, a.metrics)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to create destinations: %v", err)
+	}
+
+	service := usecase.NewBackupService(a.setting, destinations, localBackup, a.logger)
+
+	// Очистка не удаляет неподтвержденные загрузки и нормальные копии
+	// заданий с подозрительными копиями
+	localBackup.SetProtected(func(path string) bool {
+		return journal.IsPending(path) || service.IsProtected(path)
+	})
+
+	for _, destination := range destinations {
+		if remoteBackup, ok := destination.Remote.(protectable); ok {
+			remoteBackup.SetProtected(service.IsProtected)
+		}
+	}
+
+	service.SetLocker(a.newLocker())
+	service.SetVersion(version)
+	service.SetQueue(journal)
+
+	backupCatalog := catalog.NewCatalog(a.setting.Backup.Catalog)
+	backupCatalog.SetLocker(a.newLocker())
+
+	service.SetCatalog(backupCatalog)
+
+	a.state = state.NewStore(a.setting.Daemon.State)
+	a.state.SetLocker(a.newLocker())
+
+	if err := a.state.Load(); err != nil {
+		a.logger.Error("unable to load job state", zap.Error(err))
+	}
+
+	service.SetState(a.state)
+	service.SetMetrics(a.metrics)
+
+	if a.setting.Notify.Smtp.Host != "" {
+		service.AddNotifier(notify.NewMailer(a.setting.Notify.Smtp, a.setting.Notify.Retry))
+	}
+
+	if a.setting.Notify.Telegram.Token.Ref != "" {
+		service.AddNotifier(notify.NewTelegram(a.setting.Notify.Telegram, a.setting.Notify.Retry))
+	}
+
+	for _, config := range a.setting.Notify.Webhooks {
+		webhook, err := notify.NewWebhook(config, a.setting.Notify.Retry)
+
+		if err != nil {
+			return nil, err
+		}
+
+		service.AddNotifier(webhook)
+	}
+
+	service.SetPinger(notify.NewHealthchecks(models.DefaultNotifyTimeout, a.setting.Notify.Retry))
+	service.SetCredentialsChecker(&credentialsChecker{setting: a.setting, store: a.store, logger: a.logger})
+
+	if a.setting.Lock.Remote {
+		service.EnableLeases(a.setting.Lock.Lease.Duration)
+	}
+
+	return service, nil
+}
+
+// writeTextfile записывает метрики для textfile collector node_exporter,
+// если в конфигурации задан файл
+func (a *app) writeTextfile() {
+	if a.metrics == nil || a.setting.Metrics.Textfile == "" {
+		return
+	}
+
+	if err := a.metrics.WriteTextfile(a.setting.Metrics.Textfile); err != nil {
+		a.logger.Error("unable to write metrics textfile", zap.Error(err))
+	}
+}
+
+// newLocker создает блокировки для текущей конфигурации
+func (a *app) newLocker() *lock.Locker {
+	return lock.NewLocker(a.setting.Lock.Dir, a.configPath)
+}
+
+// print выводит результат команды: JSON при --json, иначе текст из text
+func (a *app) print(value interface{}, text func()) {
+	if !a.jsonOutput {
+		text()
+		return
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(value); err != nil {
+		a.logger.Error("unable to encode output", zap.Error(err))
+	}
+}
+
+func initLog(level zapcore.Level, jsonOutput bool) (*zap.Logger, error) {
+
+	logPath := "logs"
+
+	logPath = filepath.Join(".", logPath)
+
+	err := os.MkdirAll(logPath, os.ModePerm)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fileLog, err := os.OpenFile("./logs/app.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fileLogger := zapcore.AddSync(fileLog)
+	consoleLogger := zapcore.AddSync(os.Stderr)
+
+	logger, err := zap.NewProduction()
+
+	if err != nil {
+		return logger, err
+	}
+
+	productionConfig := zap.NewDevelopmentEncoderConfig()
+	productionConfig.TimeKey = "time"
+	productionConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	productionConfig.CallerKey = "caller"
+
+	jsonEncoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+
+	developmentConfig := zap.NewDevelopmentEncoderConfig()
+	developmentConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	developmentConfig.TimeKey = "time"
+	developmentConfig.CallerKey = "caller"
+
+	consoleEncoder := zapcore.NewConsoleEncoder(developmentConfig)
+
+	if jsonOutput {
+		consoleEncoder = zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	}
+
+	core := zapcore.NewTee(
+		zapcore.NewCore(jsonEncoder, fileLogger, level),
+		zapcore.NewCore(consoleEncoder, zapcore.Lock(consoleLogger), level),
+	)
+
+	l := zap.New(core, zap.WithCaller(true))
+
+	return l, nil
+}
+
+func createDestinations(setting models.Setting, store *credentials.Store, metrics usecase.Metrics) ([]usecase.Destination, error) {
+	var destinations []usecase.Destination
+
+	clients := remote.NewClients(setting.GetAccounts(), store)
+
+	for account, client := range clients {
+		client.SetObserver(usecase.ObserveRequests(metrics, account))
+	}
+
+	for _, destination := range setting.GetDestinations() {
+		var remoteBackup usecase.RemoteBackup
+
+		switch destination.Type {
+		case models.DestinationYandex:
+			backupRemote, err := remote.NewBackupRemote(clients, destination)
+
+			if err != nil {
+				return nil, err
+			}
+
+			remoteBackup = backupRemote
+		case models.DestinationNas:
+			remoteBackup = nas.NewBackupNas(destination)
+		default:
+			return nil, fmt.Errorf("unknown type %s of destination %s", destination.Type, destination.Name)
+		}
+
+		destinations = append(destinations, usecase.Destination{Destination: destination, Remote: remoteBackup})
+	}
+
+	return destinations, nil
+}
+
+func createBackupDir(backupDir string) error {
+	backupDirPath := filepath.Join(".", backupDir)
+
+	err := os.MkdirAll(backupDirPath, os.ModePerm)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
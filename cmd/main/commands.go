@@ -0,0 +1,626 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+	"yd_backup/internal/models"
+	"yd_backup/internal/repo/state"
+	"yd_backup/internal/usecase"
+)
+
+func runBackup(a *app, args []string) int {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	noPrune := flags.Bool("no-prune", false, "не удалять устаревшие копии после копирования")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	service, err := a.newService()
+
+	if err != nil {
+		a.logger.Error("unable to start backup", zap.Error(err))
+		return exitFailure
+	}
+
+	service.CheckStale()
+	service.CheckCredentials()
+
+	outcomes, status, err := service.BackupAll(flags.Args())
+
+	if err != nil {
+		a.logger.Error("backup failed", zap.Error(err))
+		return exitUsage
+	}
+
+	// Итоги заданий в текстовом режиме уже выведены в лог
+	if a.jsonOutput {
+		a.print(outcomes, nil)
+	}
+
+	if !*noPrune && service.EraseBackup() != usecase.StatusSuccess && status == usecase.StatusSuccess {
+		status = usecase.StatusPartial
+	}
+
+	a.writeTextfile()
+
+	return exitCode(status)
+}
+
+func runPrune(a *app, args []string) int {
+	service, err := a.newService()
+
+	if err != nil {
+		a.logger.Error("unable to start prune", zap.Error(err))
+		return exitFailure
+	}
+
+	status := service.EraseBackup()
+
+	a.writeTextfile()
+
+	return exitCode(status)
+}
+
+func runList(a *app, args []string) int {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	live := flags.Bool("live", false, "запросить список у назначений вместо каталога")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	service, err := a.newService()
+
+	if err != nil {
+		a.logger.Error("unable to start list", zap.Error(err))
+		return exitFailure
+	}
+
+	list := service.List
+
+	if *live {
+		list = service.ListRemote
+	}
+
+	listings, status, err := list(flags.Args())
+
+	if err != nil {
+		a.logger.Error("list failed", zap.Error(err))
+		return exitUsage
+	}
+
+	a.print(listings, func() {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(writer, "DESTINATION\tNAME\tSIZE\tCREATED\tPINNED")
+
+		for _, listing := range listings {
+			if listing.Error != "" {
+				fmt.Fprintf(writer, "%s\t<error: %s>\t\t\t\n", listing.Destination, listing.Error)
+				continue
+			}
+
+			for _, file := range listing.Files {
+				pinned := ""
+
+				if file.Pinned {
+					pinned = "pinned"
+				}
+
+				fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t%s\n", listing.Destination, file.Name, file.Size,
+					file.Created.Local().Format(time.DateTime), pinned)
+			}
+		}
+
+		writer.Flush()
+	})
+
+	return exitCode(status)
+}
+
+func runRestore(a *app, args []string) int {
+	if len(args) < 2 || len(args) > 3 {
+		fmt.Fprintln(os.Stderr, "usage: restore <destination> <name> [path]")
+		return exitUsage
+	}
+
+	service, err := a.newService()
+
+	if err != nil {
+		a.logger.Error("unable to start restore", zap.Error(err))
+		return exitFailure
+	}
+
+	var targetPath string
+
+	if len(args) == 3 {
+		targetPath = args[2]
+	}
+
+	path, err := service.Restore(args[0], args[1], targetPath)
+
+	if err != nil {
+		a.logger.Error("restore failed", zap.Error(err))
+		return exitFailure
+	}
+
+	a.print(map[string]string{"path": path}, func() {
+		fmt.Println(path)
+	})
+
+	return exitSuccess
+}
+
+func runVerify(a *app, args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	restore := flags.Bool("restore", false, "проверить копии скачиванием и сверкой хеша")
+	count := flags.Int("count", a.setting.Verify.Count, "число копий для проверки восстановлением")
+	random := flags.Bool("random", a.setting.Verify.Random, "проверять случайные копии")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	service, err := a.newService()
+
+	if err != nil {
+		a.logger.Error("unable to start verify", zap.Error(err))
+		return exitFailure
+	}
+
+	defer a.writeTextfile()
+
+	service.RefreshMetrics()
+
+	if *restore {
+		checks, status := service.TestRestore(*count, *random)
+
+		a.print(checks, func() {
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+			fmt.Fprintln(writer, "DESTINATION\tNAME\tRESULT\tDURATION\tDETAIL")
+
+			for _, check := range checks {
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", check.Destination, check.Name, check.Result,
+					check.Duration.Round(time.Millisecond), check.Detail)
+			}
+
+			writer.Flush()
+		})
+
+		return exitCode(status)
+	}
+
+	verifications, status := service.Verify()
+
+	a.print(verifications, func() {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(writer, "DESTINATION\tNAME\tRESULT")
+
+		for _, verification := range verifications {
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", verification.Destination, verification.Name, verification.Result)
+		}
+
+		writer.Flush()
+	})
+
+	return exitCode(status)
+}
+
+func runCatalog(a *app, args []string) int {
+	if len(args) != 1 || (args[0] != "rebuild" && args[0] != "reconcile") {
+		fmt.Fprintln(os.Stderr, "usage: catalog rebuild | catalog reconcile")
+		return exitUsage
+	}
+
+	service, err := a.newService()
+
+	if err != nil {
+		a.logger.Error("unable to start catalog", zap.Error(err))
+		return exitFailure
+	}
+
+	if args[0] == "rebuild" {
+		entries, status := service.RebuildCatalog()
+
+		a.print(entries, func() {
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+			fmt.Fprintln(writer, "ID\tJOB\tSIZE\tCREATED\tDESTINATIONS")
+
+			for _, entry := range entries {
+				var destinations []string
+
+				for _, catalogCopy := range entry.Copies {
+					destinations = append(destinations, catalogCopy.Destination)
+				}
+
+				fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t%s\n", entry.ID, entry.Job, entry.Size, formatTime(entry.Created),
+					strings.Join(destinations, ","))
+			}
+
+			writer.Flush()
+		})
+
+		return exitCode(status)
+	}
+
+	discrepancies, status := service.Reconcile()
+
+	a.print(discrepancies, func() {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(writer, "DESTINATION\tNAME\tKIND\tDETAIL")
+
+		for _, discrepancy := range discrepancies {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", discrepancy.Destination, discrepancy.Name, discrepancy.Kind,
+				discrepancy.Detail)
+		}
+
+		writer.Flush()
+	})
+
+	return exitCode(status)
+}
+
+func runPin(a *app, args []string) int {
+	flags := flag.NewFlagSet("pin", flag.ContinueOnError)
+	reason := flags.String("reason", "", "причина закрепления, например проверка или обновление конфигурации")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: pin [--reason text] <name>")
+		return exitUsage
+	}
+
+	return changePin(a, func(service *usecase.BackupService) (models.CatalogEntry, usecase.Status, error) {
+		return service.Pin(flags.Arg(0), *reason)
+	})
+}
+
+func runUnpin(a *app, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: unpin <name>")
+		return exitUsage
+	}
+
+	return changePin(a, func(service *usecase.BackupService) (models.CatalogEntry, usecase.Status, error) {
+		return service.Unpin(args[0])
+	})
+}
+
+func changePin(a *app, change func(service *usecase.BackupService) (models.CatalogEntry, usecase.Status, error)) int {
+	service, err := a.newService()
+
+	if err != nil {
+		a.logger.Error("unable to start pin", zap.Error(err))
+		return exitFailure
+	}
+
+	entry, status, err := change(service)
+
+	if err != nil {
+		a.logger.Error("pin failed", zap.Error(err))
+		return exitFailure
+	}
+
+	a.print(entry, func() {
+		fmt.Printf("%s pinned: %t\n", entry.ID, entry.Pinned)
+	})
+
+	return exitCode(status)
+}
+
+const trashUsage = "usage: trash list [destination...] | trash restore <destination> <name> | " +
+	"trash delete <destination> <name> | trash purge [--all] [destination...]"
+
+func runTrash(a *app, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, trashUsage)
+		return exitUsage
+	}
+
+	service, err := a.newService()
+
+	if err != nil {
+		a.logger.Error("unable to start trash", zap.Error(err))
+		return exitFailure
+	}
+
+	switch args[0] {
+	case "list":
+		listings, status, err := service.ListTrash(args[1:])
+
+		if err != nil {
+			a.logger.Error("trash list failed", zap.Error(err))
+			return exitUsage
+		}
+
+		a.print(listings, func() {
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+			fmt.Fprintln(writer, "DESTINATION\tNAME\tSIZE\tDELETED\tORIGIN")
+
+			for _, listing := range listings {
+				if listing.Error != "" {
+					fmt.Fprintf(writer, "%s\t<error: %s>\t\t\t\n", listing.Destination, listing.Error)
+					continue
+				}
+
+				for _, file := range listing.Files {
+					fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t%s\n", listing.Destination, file.Name, file.Size,
+						formatTime(file.Deleted), file.OriginPath)
+				}
+			}
+
+			writer.Flush()
+		})
+
+		return exitCode(status)
+	case "restore", "delete":
+		if len(args) != 3 {
+			fmt.Fprintln(os.Stderr, trashUsage)
+			return exitUsage
+		}
+
+		if args[0] == "delete" {
+			if err := service.RemoveTrash(args[1], args[2]); err != nil {
+				a.logger.Error("trash delete failed", zap.Error(err))
+				return exitFailure
+			}
+
+			return exitSuccess
+		}
+
+		path, err := service.RestoreTrash(args[1], args[2])
+
+		if err != nil {
+			a.logger.Error("trash restore failed", zap.Error(err))
+			return exitFailure
+		}
+
+		a.print(map[string]string{"path": path}, func() {
+			fmt.Println(path)
+		})
+
+		return exitSuccess
+	case "purge":
+		flags := flag.NewFlagSet("trash purge", flag.ContinueOnError)
+		all := flags.Bool("all", false, "удалить все копии назначений из корзины, не дожидаясь trash_grace")
+
+		if err := flags.Parse(args[1:]); err != nil {
+			return exitUsage
+		}
+
+		paths, status, err := service.PurgeTrash(flags.Args(), *all)
+
+		if err != nil {
+			a.logger.Error("trash purge failed", zap.Error(err))
+			return exitUsage
+		}
+
+		a.print(paths, func() {
+			for _, path := range paths {
+				fmt.Println(path)
+			}
+		})
+
+		return exitCode(status)
+	default:
+		fmt.Fprintln(os.Stderr, trashUsage)
+		return exitUsage
+	}
+}
+
+func runConfig(a *app, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: config validate | config show")
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "validate":
+		err := a.checkSetting()
+
+		var validationError models.ValidationError
+
+		if errors.As(err, &validationError) {
+			a.print(validationError, func() {
+				for _, e := range validationError {
+					fmt.Printf("%s: %s\n", e.Field, e.Message)
+				}
+			})
+
+			return exitFailure
+		}
+
+		if err != nil {
+			a.logger.Error("invalid config", zap.Error(err))
+			return exitFailure
+		}
+
+		a.logger.Info("config is valid")
+
+		return exitSuccess
+	case "show":
+		a.jsonOutput = true
+		a.print(a.setting, nil)
+
+		return exitSuccess
+	default:
+		fmt.Fprintln(os.Stderr, "usage: config validate | config show")
+		return exitUsage
+	}
+}
+
+func runLogin(a *app, args []string) int {
+	if err := a.loadSecrets(); err != nil {
+		a.logger.Error("login failed", zap.Error(err))
+		return exitFailure
+	}
+
+	if err := login(a.setting, a.store, args); err != nil {
+		a.logger.Error("login failed", zap.Error(err))
+		return exitFailure
+	}
+
+	return exitSuccess
+}
+
+func runVault(a *app, args []string) int {
+	vault, err := openVault(a.setting)
+
+	if err != nil {
+		a.logger.Error("unable to open vault", zap.Error(err))
+		return exitFailure
+	}
+
+	if err := vaultCommand(vault, args); err != nil {
+		a.logger.Error("vault command failed", zap.Error(err))
+		return exitUsage
+	}
+
+	return exitSuccess
+}
+
+func runDaemon(a *app, args []string) int {
+	unlock, err := a.newLocker().Lock("daemon")
+
+	if err != nil {
+		a.logger.Error("daemon is already running", zap.Error(err))
+		return exitFailure
+	}
+
+	defer unlock()
+
+	service, err := a.newService()
+
+	if err != nil {
+		a.logger.Error("unable to start daemon", zap.Error(err))
+		return exitFailure
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if a.setting.Metrics.Listen != "" {
+		service.RefreshMetrics()
+
+		go serveMetrics(ctx, a.setting.Metrics.Listen, a.metrics, a.logger)
+	}
+
+	a.logger.Info("Daemon started")
+
+	if err := usecase.NewScheduler(service, a.state, a.logger).Run(ctx); err != nil {
+		a.logger.Error("daemon failed", zap.Error(err))
+		return exitFailure
+	}
+
+	a.logger.Info("Daemon stopped")
+
+	return exitSuccess
+}
+
+// runWatchdog проверяет возраст последних успешных копий заданий с max_age.
+// Код завершения 2, если есть устаревшие копии
+func runWatchdog(a *app, args []string) int {
+	service, err := a.newService()
+
+	if err != nil {
+		a.logger.Error("unable to start watchdog", zap.Error(err))
+		return exitFailure
+	}
+
+	stale, status := service.CheckStale()
+
+	a.print(stale, func() {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(writer, "JOB\tLAST SUCCESS\tAGE\tMAX AGE")
+
+		for _, job := range stale {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", job.Job, formatTime(job.LastSuccess),
+				job.Age.Round(time.Minute), job.MaxAge)
+		}
+
+		writer.Flush()
+	})
+
+	return exitCode(status)
+}
+
+// jobStatus - строка вывода команды status
+type jobStatus struct {
+	Job      string    `json:"job"`
+	Schedule string    `json:"schedule"`
+	LastRun  time.Time `json:"last_run"`
+	Status   string    `json:"status"`
+	NextRun  time.Time `json:"next_run"`
+}
+
+func runStatus(a *app, args []string) int {
+	store := state.NewStore(a.setting.Daemon.State)
+
+	if err := store.Load(); err != nil {
+		a.logger.Error("unable to load daemon state", zap.Error(err))
+		return exitFailure
+	}
+
+	var result []jobStatus
+
+	for _, files := range a.setting.Files {
+		job := jobStatus{Job: files.Name}
+
+		switch {
+		case files.Schedule != "":
+			job.Schedule = files.Schedule
+		case files.Interval.Duration > 0:
+			job.Schedule = "every " + files.Interval.String()
+		}
+
+		if jobState, ok := store.Get(files.Name); ok {
+			job.LastRun = jobState.LastRun
+			job.Status = jobState.LastStatus
+			job.NextRun = jobState.NextRun
+		}
+
+		result = append(result, job)
+	}
+
+	a.print(result, func() {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(writer, "JOB\tSCHEDULE\tLAST RUN\tSTATUS\tNEXT RUN")
+
+		for _, job := range result {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", job.Job, job.Schedule, formatTime(job.LastRun), job.Status,
+				formatTime(job.NextRun))
+		}
+
+		writer.Flush()
+	})
+
+	return exitSuccess
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Local().Format(time.DateTime)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"yd_backup/internal/models"
+	"yd_backup/internal/repo/credentials"
+	"yd_backup/internal/secrets"
+	"yd_backup/internal/usecase"
+	"yd_backup/pkg/yandex/oauth"
+)
+
+// openVault открывает хранилище секретов, если оно задано в конфигурации.
+// Мастер-ключ сам может быть ссылкой env: или file:
+func openVault(setting models.Setting) (*secrets.Vault, error) {
+	if setting.Vault.Path == "" {
+		return nil, nil
+	}
+
+	key := setting.GetVaultKey()
+
+	if err := key.Resolve(secrets.NewResolver(nil).Resolve); err != nil {
+		return nil, err
+	}
+
+	vault := secrets.NewVault(setting.Vault.Path, key.Value())
+
+	if err := vault.Open(); err != nil {
+		return nil, err
+	}
+
+	return vault, nil
+}
+
+// vaultCommand: vault set <name> читает значение секрета из stdin,
+// vault list выводит имена сохраненных секретов
+func vaultCommand(vault *secrets.Vault, args []string) error {
+	if vault == nil {
+		return fmt.Errorf("vault.path is not set")
+	}
+
+	if len(args) == 1 && args[0] == "list" {
+		for _, name := range vault.Names() {
+			fmt.Println(name)
+		}
+
+		return nil
+	}
+
+	if len(args) != 2 || args[0] != "set" {
+		return fmt.Errorf("usage: vault set <name> | vault list")
+	}
+
+	value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	return vault.Set(args[1], strings.TrimRight(value, "\r\n"))
+}
+
+// login выполняет вход в Yandex OAuth по коду устройства для аккаунта из
+// аргументов (или единственного аккаунта) и сохраняет токены в credentials
+func login(setting models.Setting, store *credentials.Store, args []string) error {
+	accounts := setting.GetAccounts()
+
+	var account *models.Account
+
+	for i := range accounts {
+		if (len(args) == 0 && len(accounts) == 1) || (len(args) > 0 && accounts[i].Name == args[0]) {
+			account = &accounts[i]
+		}
+	}
+
+	if account == nil {
+		return fmt.Errorf("account not found, usage: login <account>")
+	}
+
+	if account.ClientID == "" {
+		return fmt.Errorf("client_id is not set for account %s", account.Name)
+	}
+
+	client := newOAuthClient(*account)
+
+	code, err := client.DeviceCode()
+
+	if err != nil {
+		return fmt.Errorf("unable to get device code: %v", err)
+	}
+
+	fmt.Printf("Откройте %s и введите код %s\n", code.VerificationUrl, code.UserCode)
+
+	token, err := client.PollToken(code)
+
+	if err != nil {
+		return fmt.Errorf("unable to get token: %v", err)
+	}
+
+	if err := store.Set(account.Name, token); err != nil {
+		return err
+	}
+
+	fmt.Printf("Вход для аккаунта %s выполнен, токен действует до %s\n", account.Name, token.ExpiresAt.Format(time.DateTime))
+
+	return nil
+}
+
+// newOAuthClient создает клиент Yandex OAuth для аккаунта
+var newOAuthClient = func(account models.Account) *oauth.Client {
+	return oauth.NewClient(account.ClientID, account.ClientSecret.Value())
+}
+
+// credentialsChecker проверяет токены для сервиса: команда backup перед
+// запуском, демон - вместе с watchdog
+type credentialsChecker struct {
+	setting models.Setting
+	store   *credentials.Store
+	logger  *zap.Logger
+}
+
+// CheckCredentials перечитывает файл токенов, чтобы демон увидел вход,
+// выполненный командой login после его запуска
+func (c *credentialsChecker) CheckCredentials() []usecase.TokenCheck {
+	if err := c.store.Load(); err != nil {
+		c.logger.Error("unable to load credentials", zap.Error(err))
+	}
+
+	return checkCredentials(c.setting, c.store, c.logger)
+}
+
+// checkCredentials предупреждает о скором истечении сохраненных токенов и
+// заранее обновляет уже истекшие, если для аккаунта задан client_id.
+// Возвращает токены, о которых нужно уведомить
+func checkCredentials(setting models.Setting, store *credentials.Store, logger *zap.Logger) []usecase.TokenCheck {
+	var checks []usecase.TokenCheck
+
+	for _, account := range setting.GetAccounts() {
+		token, ok := store.Get(account.Name)
+
+		if !ok || token.ExpiresAt.IsZero() {
+			continue
+		}
+
+		log := logger.With(zap.String("Account", account.Name)).With(zap.Time("expires_at", token.ExpiresAt))
+
+		check := usecase.TokenCheck{Account: account.Name, ExpiresAt: token.ExpiresAt}
+
+		remaining := time.Until(token.ExpiresAt)
+
+		if remaining <= 0 && account.ClientID != "" {
+			refresher := credentials.NewRefresher(store, account.Name, newOAuthClient(account))
+
+			if _, err := refresher.Refresh(); err != nil {
+				log.With(zap.Error(err)).Error("Token expired and could not be refreshed, run login")
+
+				check.Result, check.Detail = usecase.TokenRefreshFailed, err.Error()
+				checks = append(checks, check)
+			} else {
+				log.Info("Expired token refreshed")
+			}
+
+			continue
+		}
+
+		if remaining <= 0 {
+			log.Error("Token expired, run login")
+
+			check.Result = usecase.TokenExpired
+			checks = append(checks, check)
+		} else if remaining < account.ExpiryWarning.Duration {
+			log.With(zap.Int("days_left", int(remaining.Hours()/24))).Warn("Token expires soon")
+
+			check.Result = usecase.TokenExpiresSoon
+			checks = append(checks, check)
+		}
+	}
+
+	return checks
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	entity "yd_backup/internal/models"
+	"yd_backup/internal/repo/credentials"
+	"yd_backup/internal/usecase"
+	"yd_backup/pkg/yandex/oauth"
+	"yd_backup/pkg/yandex/oauth/models"
+)
+
+// fakeOAuth - сервер Yandex OAuth, который обновляет токен по refresh-токену
+// "valid" и отвечает invalid_grant на любой другой
+func fakeOAuth(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/token" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("unable to parse form: %v", err)
+		}
+
+		if grant := r.PostForm.Get("grant_type"); grant != "refresh_token" {
+			t.Errorf("grant_type = %q, want refresh_token", grant)
+		}
+
+		if clientID := r.PostForm.Get("client_id"); clientID != "client" {
+			t.Errorf("client_id = %q, want client", clientID)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.PostForm.Get("refresh_token") != "valid" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant", "error_description": "expired refresh token"}`))
+			return
+		}
+
+		w.Write([]byte(`{"access_token": "new", "refresh_token": "next", "expires_in": 31536000}`))
+	}))
+
+	t.Cleanup(server.Close)
+
+	previous := newOAuthClient
+
+	newOAuthClient = func(account entity.Account) *oauth.Client {
+		client := oauth.NewClient(account.ClientID, account.ClientSecret.Value())
+		client.URL = server.URL
+
+		return client
+	}
+
+	t.Cleanup(func() { newOAuthClient = previous })
+
+	return server
+}
+
+func newTestStore(t *testing.T, tokens map[string]models.Token) *credentials.Store {
+	t.Helper()
+
+	store := credentials.NewStore(filepath.Join(t.TempDir(), "credentials.json"))
+
+	for account, token := range tokens {
+		if err := store.Set(account, token); err != nil {
+			t.Fatalf("unable to save token: %v", err)
+		}
+	}
+
+	return store
+}
+
+type recordNotifier struct {
+	mu      sync.Mutex
+	reports []usecase.Report
+}
+
+func (n *recordNotifier) Name() string {
+	return "record"
+}
+
+func (n *recordNotifier) Notify(report usecase.Report) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.reports = append(n.reports, report)
+
+	return nil
+}
+
+func TestCheckCredentials(t *testing.T) {
+	fakeOAuth(t)
+
+	now := time.Now()
+
+	setting := entity.Setting{
+		Accounts: []entity.Account{
+			{Name: "refreshed", ClientID: "client"},
+			{Name: "revoked", ClientID: "client"},
+			{Name: "expired"},
+			{Name: "soon"},
+			{Name: "fresh"},
+		},
+	}
+
+	store := newTestStore(t, map[string]models.Token{
+		"refreshed": {AccessToken: "old", RefreshToken: "valid", ExpiresAt: now.Add(-time.Hour)},
+		"revoked":   {AccessToken: "old", RefreshToken: "revoked", ExpiresAt: now.Add(-time.Hour)},
+		"expired":   {AccessToken: "old", ExpiresAt: now.Add(-time.Hour)},
+		"soon":      {AccessToken: "old", ExpiresAt: now.Add(24 * time.Hour)},
+		"fresh":     {AccessToken: "old", ExpiresAt: now.Add(365 * 24 * time.Hour)},
+	})
+
+	checks := checkCredentials(setting, store, zap.NewNop())
+
+	results := make(map[string]usecase.TokenCheck)
+
+	for _, check := range checks {
+		results[check.Account] = check
+	}
+
+	want := map[string]string{
+		"revoked": usecase.TokenRefreshFailed,
+		"expired": usecase.TokenExpired,
+		"soon":    usecase.TokenExpiresSoon,
+	}
+
+	if len(results) != len(want) {
+		t.Errorf("checks = %+v, want accounts %v", checks, want)
+	}
+
+	for account, result := range want {
+		if results[account].Result != result {
+			t.Errorf("%s: result = %q, want %q", account, results[account].Result, result)
+		}
+	}
+
+	if results["revoked"].Detail == "" {
+		t.Errorf("revoked: refresh error is not reported")
+	}
+
+	token, _ := store.Get("refreshed")
+
+	if token.AccessToken != "new" || token.RefreshToken != "next" || !token.ExpiresAt.After(now) {
+		t.Errorf("refreshed token = %+v, want new token from OAuth", token)
+	}
+
+	// Предупреждения уходят в уведомления, а не только в лог
+	notifier := &recordNotifier{}
+
+	service := usecase.NewBackupService(entity.Setting{}, nil, nil, zap.NewNop())
+	service.AddNotifier(notifier)
+	service.NotifyCredentials(checks)
+
+	if len(notifier.reports) != 1 {
+		t.Fatalf("notifications = %d, want 1", len(notifier.reports))
+	}
+
+	report := notifier.reports[0]
+
+	if report.Kind != usecase.ReportCredentials || report.Status != usecase.StatusFailed || len(report.Tokens) != 3 {
+		t.Errorf("report = %+v, want failed credentials report with 3 tokens", report)
+	}
+}
+
+func TestCheckCredentialsExpiresSoon(t *testing.T) {
+	fakeOAuth(t)
+
+	setting := entity.Setting{
+		Accounts: []entity.Account{
+			{Name: "soon", ExpiryWarning: entity.Duration{Duration: 48 * time.Hour}},
+		},
+	}
+
+	store := newTestStore(t, map[string]models.Token{
+		"soon": {AccessToken: "old", ExpiresAt: time.Now().Add(36 * time.Hour)},
+	})
+
+	checks := checkCredentials(setting, store, zap.NewNop())
+
+	if len(checks) != 1 || checks[0].Result != usecase.TokenExpiresSoon {
+		t.Fatalf("checks = %+v, want one expiring token", checks)
+	}
+
+	notifier := &recordNotifier{}
+
+	service := usecase.NewBackupService(entity.Setting{}, nil, nil, zap.NewNop())
+	service.AddNotifier(notifier)
+	service.NotifyCredentials(checks)
+
+	if len(notifier.reports) != 1 || notifier.reports[0].Status != usecase.StatusPartial {
+		t.Fatalf("reports = %+v, want one partial report", notifier.reports)
+	}
+
+	// Политика never отключает и эти уведомления
+	silent := &recordNotifier{}
+
+	service = usecase.NewBackupService(entity.Setting{Notify: entity.Notify{Policy: entity.NotifyNever}}, nil, nil, zap.NewNop())
+	service.AddNotifier(silent)
+	service.NotifyCredentials(checks)
+
+	if len(silent.reports) != 0 {
+		t.Errorf("reports = %d with policy never, want 0", len(silent.reports))
+	}
+}
@@ -1,128 +1,160 @@
 package main
 
 import (
-	"encoding/json"
+	"flag"
+	"fmt"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"log"
 	"os"
-	"path/filepath"
-	"yd_backup/internal/models"
-	"yd_backup/internal/repo/local"
-	"yd_backup/internal/repo/remote"
+	"yd_backup/internal/config"
 	"yd_backup/internal/usecase"
 )
 
-func main() {
-	logger, err := initLog()
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	defer logger.Sync()
-
-	setting, err := readSetting()
-
-	if err != nil {
-		logger.Info("unable to read config file", zap.Error(err))
-	}
-
-	logger.Debug("config", zap.Any("config", setting))
-
-	if err := createBackupDir(setting.Backup.Dir); err != nil {
-		logger.Fatal("unable to create backup dir", zap.Error(err))
-	}
-
-	localBackup := local.NewBackupLocal(setting)
-	remoteBackup := remote.NewBackupRemote(setting)
-
-	service := usecase.NewBackupService(setting, remoteBackup, localBackup, logger)
-
-	service.BackupAll()
-
-	service.EraseBackup()
+// Коды завершения для скриптов мониторинга
+const (
+	exitSuccess = 0
+	exitPartial = 1
+	exitFailure = 2
+	exitUsage   = 3
+)
 
+const defaultConfig = "./config/config.json"
+
+// version - версия программы, задается при сборке:
+// go build -ldflags "-X main.version=1.2.0"
+var version = "dev"
+
+const usage = `Usage: yd_backup [flags] <command> [args]
+
+Commands:
+  backup [--no-prune] [job...]         копирование заданий (без имен - всех) и удаление устаревших копий
+  prune                                удаление устаревших копий
+  list [--live] [destination...]       список копий в назначениях по каталогу
+  restore <destination> <name> [path]  скачивание копии из назначения
+  verify [--restore [--count n] [--random]]
+                                       сверка копий из каталога с назначениями или
+                                       проверка восстановлением
+  catalog rebuild                      восстановление каталога по назначениям
+  catalog reconcile                    расхождения каталога с назначениями
+  pin [--reason text] <name>           закрепление копии: очистка ее не удаляет
+  unpin <name>                         снятие закрепления копии
+  trash list [destination...]          копии назначений в корзине Yandex Disk
+  trash restore <destination> <name>   восстановление копии из корзины
+  trash delete <destination> <name>    удаление копии из корзины
+  trash purge [--all] [destination...] удаление из корзины копий старше trash_grace
+  daemon                               запуск заданий по расписаниям
+  status                               последний и следующий запуск заданий
+  watchdog                             проверка возраста последних успешных копий
+  config validate                      проверка конфигурации
+  config show                          вывод конфигурации со скрытыми секретами
+  login [account]                      вход в Yandex OAuth
+  vault set <name> | vault list        работа с хранилищем секретов
+
+Без команды выполняется backup.
+
+Flags:
+`
+
+type command func(a *app, args []string) int
+
+var commands = map[string]command{
+	"backup":   runBackup,
+	"prune":    runPrune,
+	"list":     runList,
+	"restore":  runRestore,
+	"verify":   runVerify,
+	"catalog":  runCatalog,
+	"pin":      runPin,
+	"unpin":    runUnpin,
+	"trash":    runTrash,
+	"config":   runConfig,
+	"daemon":   runDaemon,
+	"status":   runStatus,
+	"watchdog": runWatchdog,
+	"login":    runLogin,
+	"vault":    runVault,
 }
 
-func initLog() (*zap.Logger, error) {
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
 
-	logPath := "logs"
+func run(args []string) int {
+	flags := flag.NewFlagSet("yd_backup", flag.ContinueOnError)
 
-	logPath = filepath.Join(".", logPath)
+	configPath := flags.String("config", defaultConfig, "путь к файлу конфигурации")
+	logLevel := flags.String("log-level", "debug", "уровень логирования: debug, info, warn, error")
+	jsonOutput := flags.Bool("json", false, "вывод результатов и логов консоли в JSON")
 
-	err := os.MkdirAll(logPath, os.ModePerm)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
 
-	if err != nil {
-		return nil, err
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
 	}
 
-	fileLog, err := os.OpenFile("./logs/app.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	level, err := zapcore.ParseLevel(*logLevel)
 
 	if err != nil {
-		return nil, err
+		fmt.Fprintf(flags.Output(), "invalid log level %s\n", *logLevel)
+		return exitUsage
 	}
 
-	fileLogger := zapcore.AddSync(fileLog)
-	consoleLogger := zapcore.AddSync(os.Stdout)
-
-	logger, err := zap.NewProduction()
+	logger, err := initLog(level, *jsonOutput)
 
 	if err != nil {
-		return logger, err
+		log.Println(err)
+		return exitFailure
 	}
 
-	productionConfig := zap.NewDevelopmentEncoderConfig()
-	productionConfig.TimeKey = "time"
-	productionConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	productionConfig.CallerKey = "caller"
-
-	jsonEncoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
-
-	developmentConfig := zap.NewDevelopmentEncoderConfig()
-	developmentConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-	developmentConfig.TimeKey = "time"
-	developmentConfig.CallerKey = "caller"
-
-	consoleEncoder := zapcore.NewConsoleEncoder(developmentConfig)
+	defer logger.Sync()
 
-	core := zapcore.NewTee(
-		zapcore.NewCore(jsonEncoder, fileLogger, zapcore.DebugLevel),
-		zapcore.NewCore(consoleEncoder, consoleLogger, zapcore.DebugLevel),
-	)
+	setting, err := config.Load(*configPath)
 
-	l := zap.New(core, zap.WithCaller(true))
+	logger.Debug("config", zap.Any("config", setting))
 
-	return l, nil
-}
+	a := &app{
+		setting:    setting,
+		configPath: *configPath,
+		settingErr: err,
+		logger:     logger,
+		jsonOutput: *jsonOutput,
+	}
 
-func readSetting() (models.Setting, error) {
-	fileSetting, err := os.Open("./config/config.json")
+	name, commandArgs := "backup", flags.Args()
 
-	if err != nil {
-		return models.Setting{}, err
+	if len(commandArgs) > 0 {
+		name, commandArgs = commandArgs[0], commandArgs[1:]
 	}
 
-	decoder := json.NewDecoder(fileSetting)
+	cmd, ok := commands[name]
 
-	var setting models.Setting
-	err = decoder.Decode(&setting)
+	if !ok {
+		fmt.Fprintf(flags.Output(), "unknown command %s\n\n", name)
+		flags.Usage()
+		return exitUsage
+	}
 
-	if err != nil {
-		return models.Setting{}, err
+	if name != "config" {
+		if err := a.checkSetting(); err != nil {
+			logger.Error("invalid config", zap.String("config", *configPath), zap.Error(err))
+			return exitFailure
+		}
 	}
 
-	return setting, nil
+	return cmd(a, commandArgs)
 }
 
-func createBackupDir(backupDir string) error {
-	backupDirPath := filepath.Join(".", backupDir)
-
-	err := os.MkdirAll(backupDirPath, os.ModePerm)
-
-	if err != nil {
-		return err
+func exitCode(status usecase.Status) int {
+	switch status {
+	case usecase.StatusSuccess:
+		return exitSuccess
+	case usecase.StatusPartial:
+		return exitPartial
+	default:
+		return exitFailure
 	}
-
-	return nil
 }
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// serveMetrics отдает метрики по адресу /metrics до отмены ctx
+func serveMetrics(ctx context.Context, listen string, handler http.Handler, logger *zap.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+
+	server := &http.Server{Addr: listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("Metrics server started", zap.String("listen", listen))
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("metrics server failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,294 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"yd_backup/internal/models"
+)
+
+const includeKey = "include"
+
+// Load читает конфигурацию в формате JSON, YAML или TOML по расширению
+// файла, подключает файлы из include, подставляет переменные окружения в
+// строковые значения, кроме секретов, и заполняет значения по умолчанию
+func Load(path string) (models.Setting, error) {
+	var setting models.Setting
+
+	values, err := load(path, nil)
+
+	if err != nil {
+		return setting, err
+	}
+
+	data, err := json.Marshal(interpolate(values, reflect.TypeOf(setting)))
+
+	if err != nil {
+		return setting, err
+	}
+
+	if err := json.Unmarshal(data, &setting); err != nil {
+		return setting, fmt.Errorf("%s: %v", path, err)
+	}
+
+	setting.SetDefaults()
+
+	return setting, nil
+}
+
+// load читает файл и подключает его include. Сначала берутся значения
+// самого файла, затем поверх них файлы include в порядке шаблонов, а внутри
+// шаблона - в лексикографическом порядке имен
+func load(path string, stack []string) (map[string]interface{}, error) {
+	absPath, err := filepath.Abs(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, parent := range stack {
+		if parent == absPath {
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(append(stack, absPath), " -> "))
+		}
+	}
+
+	values, err := parse(absPath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	patterns, err := includes(values[includeKey])
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	delete(values, includeKey)
+
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(absPath), pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid include %s: %v", path, pattern, err)
+		}
+
+		sort.Strings(matches)
+
+		for _, match := range matches {
+			included, err := load(match, append(stack, absPath))
+
+			if err != nil {
+				return nil, err
+			}
+
+			values = merge(values, included).(map[string]interface{})
+		}
+	}
+
+	return values, nil
+}
+
+func parse(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("%s: unsupported config format, expected .json, .yaml, .yml or .toml", path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return values, nil
+}
+
+func includes(value interface{}) ([]string, error) {
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{value}, nil
+	case []interface{}:
+		var result []string
+
+		for _, item := range value {
+			pattern, ok := item.(string)
+
+			if !ok {
+				return nil, fmt.Errorf("include must be a string or a list of strings")
+			}
+
+			result = append(result, pattern)
+		}
+
+		return result, nil
+	default:
+		return nil, fmt.Errorf("include must be a string or a list of strings")
+	}
+}
+
+// merge накладывает src на dst: объекты сливаются по ключам, списки
+// дополняются, остальные значения из src заменяют значения dst
+func merge(dst interface{}, src interface{}) interface{} {
+	switch srcValue := src.(type) {
+	case map[string]interface{}:
+		dstValue, ok := dst.(map[string]interface{})
+
+		if !ok {
+			return srcValue
+		}
+
+		for key, value := range srcValue {
+			if existing, ok := dstValue[key]; ok {
+				dstValue[key] = merge(existing, value)
+			} else {
+				dstValue[key] = value
+			}
+		}
+
+		return dstValue
+	case []interface{}:
+		dstValue, ok := dst.([]interface{})
+
+		if !ok {
+			return srcValue
+		}
+
+		return append(dstValue, srcValue...)
+	default:
+		return src
+	}
+}
+
+var secretType = reflect.TypeOf(models.Secret{})
+
+// interpolate подставляет ${NAME} и ${NAME:-default} в строковые значения.
+// valueType - тип, в который будет прочитано значение; по нему пропускаются
+// поля models.Secret, чтобы пароль или токен с $ не менялся. Секрет из
+// окружения задается ссылкой env:NAME
+func interpolate(value interface{}, valueType reflect.Type) interface{} {
+	for valueType != nil && valueType.Kind() == reflect.Pointer {
+		valueType = valueType.Elem()
+	}
+
+	if valueType == secretType {
+		return value
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = interpolate(item, fieldType(valueType, key))
+		}
+
+		return value
+	case []interface{}:
+		var itemType reflect.Type
+
+		if valueType != nil && (valueType.Kind() == reflect.Slice || valueType.Kind() == reflect.Array) {
+			itemType = valueType.Elem()
+		}
+
+		for i, item := range value {
+			value[i] = interpolate(item, itemType)
+		}
+
+		return value
+	case string:
+		return expand(value)
+	default:
+		return value
+	}
+}
+
+// fieldType возвращает тип значения по ключу объекта: элемент map или поле
+// структуры с таким именем в теге json. Как и encoding/json, имена
+// сравниваются без учета регистра. Для неизвестных ключей возвращает nil
+func fieldType(valueType reflect.Type, key string) reflect.Type {
+	if valueType == nil {
+		return nil
+	}
+
+	switch valueType.Kind() {
+	case reflect.Map:
+		return valueType.Elem()
+	case reflect.Struct:
+		for i := 0; i < valueType.NumField(); i++ {
+			field := valueType.Field(i)
+
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+			if name == "" {
+				name = field.Name
+			}
+
+			if strings.EqualFold(name, key) {
+				return field.Type
+			}
+		}
+	}
+
+	return nil
+}
+
+// expand раскрывает ${NAME} и ${NAME:-default}; $$ заменяется на $,
+// остальные символы $ остаются как есть
+func expand(value string) string {
+	var result strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' || i+1 >= len(value) {
+			result.WriteByte(value[i])
+			continue
+		}
+
+		if value[i+1] == '$' {
+			result.WriteByte('$')
+			i++
+			continue
+		}
+
+		end := strings.IndexByte(value[i:], '}')
+
+		if value[i+1] != '{' || end < 0 {
+			result.WriteByte(value[i])
+			continue
+		}
+
+		name, fallback, hasFallback := strings.Cut(value[i+2:i+end], ":-")
+
+		if env, ok := os.LookupEnv(name); ok && (env != "" || !hasFallback) {
+			result.WriteString(env)
+		} else {
+			result.WriteString(fallback)
+		}
+
+		i += end
+	}
+
+	return result.String()
+}
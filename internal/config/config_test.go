@@ -0,0 +1,76 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadSecretsAreNotInterpolated(t *testing.T) {
+	t.Setenv("YD_TEST_EXPIRED", "72h")
+	t.Setenv("YD_TEST_PASSWORD", "from-env")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	data := `
+backup:
+  expired: ${YD_TEST_EXPIRED}
+nas:
+  dir: $${YD_TEST_PASSWORD}
+notify:
+  smtp:
+    host: smtp.example.com
+    password: pa$$word${YD_TEST_PASSWORD}
+    from: backup@example.com
+    to: [admin@example.com]
+  webhooks:
+    - name: ops
+      url: https://hooks.example.com/${YD_TEST_PASSWORD}
+      headers:
+        Authorization: Bearer $$token
+accounts:
+  - name: org1
+    token: y0_$$${YD_TEST_PASSWORD}
+`
+
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	setting, err := Load(path)
+
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if setting.Backup.Expired.Duration != 72*time.Hour {
+		t.Errorf("backup.expired = %s, want 72h from environment", setting.Backup.Expired.Duration)
+	}
+
+	if setting.Nas.Dir != "${YD_TEST_PASSWORD}" {
+		t.Errorf("nas.dir = %q, want escaped $", setting.Nas.Dir)
+	}
+
+	if url := setting.Notify.Webhooks[0].URL; url != "https://hooks.example.com/from-env" {
+		t.Errorf("webhook url = %q, want interpolated", url)
+	}
+
+	secrets := map[string]string{
+		"smtp.password":  setting.Notify.Smtp.Password.Value(),
+		"webhook header": setting.Notify.Webhooks[0].Headers["Authorization"].Value(),
+		"account token":  setting.Accounts[0].Token.Value(),
+	}
+
+	want := map[string]string{
+		"smtp.password":  "pa$$word${YD_TEST_PASSWORD}",
+		"webhook header": "Bearer $$token",
+		"account token":  "y0_$$${YD_TEST_PASSWORD}",
+	}
+
+	for name, value := range secrets {
+		if value != want[name] {
+			t.Errorf("%s = %q, want literal %q", name, value, want[name])
+		}
+	}
+}
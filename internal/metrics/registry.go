@@ -0,0 +1,199 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"yd_backup/internal/repo"
+)
+
+const (
+	TypeGauge   = "gauge"
+	TypeCounter = "counter"
+)
+
+type series struct {
+	labels []string
+	value  float64
+}
+
+type family struct {
+	help   string
+	kind   string
+	series map[string]*series
+}
+
+// Registry - метрики в текстовом формате Prometheus. Метки передаются
+// парами имя, значение
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// Describe задает описание и тип метрики. Метрика без описания выводится
+// как gauge без HELP
+func (r *Registry) Describe(name string, help string, kind string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.family(name).help = help
+	r.family(name).kind = kind
+}
+
+// Set устанавливает значение метрики с метками
+func (r *Registry) Set(name string, value float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.series(name, labels).value = value
+}
+
+// Add увеличивает значение метрики с метками
+func (r *Registry) Add(name string, value float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.series(name, labels).value += value
+}
+
+func (r *Registry) family(name string) *family {
+	f, ok := r.families[name]
+
+	if !ok {
+		f = &family{kind: TypeGauge, series: make(map[string]*series)}
+		r.families[name] = f
+	}
+
+	return f
+}
+
+func (r *Registry) series(name string, labels []string) *series {
+	f := r.family(name)
+
+	key := strings.Join(labels, "\xff")
+
+	s, ok := f.series[key]
+
+	if !ok {
+		s = &series{labels: append([]string(nil), labels...)}
+		f.series[key] = s
+	}
+
+	return s
+}
+
+// WriteTo выводит метрики в текстовом формате экспозиции Prometheus,
+// отсортированные по имени и меткам
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+
+	var buffer bytes.Buffer
+
+	names := make([]string, 0, len(r.families))
+
+	for name := range r.families {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := r.families[name]
+
+		if len(f.series) == 0 {
+			continue
+		}
+
+		if f.help != "" {
+			fmt.Fprintf(&buffer, "# HELP %s %s\n", name, escapeHelp(f.help))
+		}
+
+		fmt.Fprintf(&buffer, "# TYPE %s %s\n", name, f.kind)
+
+		keys := make([]string, 0, len(f.series))
+
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := f.series[key]
+
+			fmt.Fprintf(&buffer, "%s%s %s\n", name, formatLabels(s.labels), formatValue(s.value))
+		}
+	}
+
+	r.mu.Unlock()
+
+	return buffer.WriteTo(w)
+}
+
+// ServeHTTP отдает метрики для Prometheus
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	r.WriteTo(w)
+}
+
+// WriteTextfile атомарно записывает метрики в файл для textfile collector
+// node_exporter. Файл должен иметь расширение .prom
+func (r *Registry) WriteTextfile(path string) error {
+	var buffer bytes.Buffer
+
+	if _, err := r.WriteTo(&buffer); err != nil {
+		return err
+	}
+
+	return repo.AtomicWrite(path, &buffer)
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) < 2 {
+		return ""
+	}
+
+	var pairs []string
+
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], escapeLabel(labels[i+1])))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(value string) string {
+	return helpEscaper.Replace(value)
+}
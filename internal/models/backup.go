@@ -0,0 +1,60 @@
+package models
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BackupFile - копия в локальной директории или в назначении. Metadata -
+// описание копии, записанное в назначение при загрузке, если оно есть
+type BackupFile struct {
+	Name     string          `json:"name"`
+	Path     string          `json:"path"`
+	Size     int64           `json:"size"`
+	Created  time.Time       `json:"created"`
+	Md5      string          `json:"md5,omitempty"`
+	Pinned   bool            `json:"pinned,omitempty"`
+	Metadata *BackupMetadata `json:"metadata,omitempty"`
+}
+
+// BackupMetadata - описание копии, которое хранится вместе с файлом в
+// назначении: по нему копию можно опознать без каталога и разбора имени.
+// ID - имя локального файла копии, Created - время создания копии, а не
+// загрузки; KeyID пуст, пока копия не зашифрована
+type BackupMetadata struct {
+	ID          string    `json:"id"`
+	Job         string    `json:"job"`
+	Host        string    `json:"host"`
+	Source      string    `json:"source"`
+	Created     time.Time `json:"created"`
+	Size        int64     `json:"size"`
+	Md5         string    `json:"md5"`
+	Sha256      string    `json:"sha256"`
+	Compression string    `json:"compression"`
+	Encryption  string    `json:"encryption"`
+	KeyID       string    `json:"key_id,omitempty"`
+	Version     string    `json:"version"`
+}
+
+// TrashFile - копия назначения в корзине. OriginPath - путь, по которому
+// копия будет восстановлена
+type TrashFile struct {
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	OriginPath string    `json:"origin_path"`
+	Size       int64     `json:"size"`
+	Deleted    time.Time `json:"deleted"`
+}
+
+// RemoteName возвращает имя копии в назначении: имя локального файла,
+// без расширения, если в назначении не включен extension
+func (d Destination) RemoteName(backupPath string) string {
+	var remoteFileName = filepath.Base(backupPath)
+
+	if !d.Extension {
+		remoteFileName = strings.TrimSuffix(remoteFileName, filepath.Ext(backupPath))
+	}
+
+	return remoteFileName
+}
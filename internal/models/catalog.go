@@ -0,0 +1,107 @@
+package models
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CatalogName - имя копии каталога в папке назначения
+const CatalogName = ".catalog.json"
+
+// Состояния копии в назначении
+const (
+	CopyUploaded = "uploaded"
+	CopyPending  = "pending"
+	CopyRemoved  = "removed"
+)
+
+// CompressionNone и EncryptionNone - копия хранится как есть
+const (
+	CompressionNone = "none"
+	EncryptionNone  = "none"
+)
+
+// CatalogEntry - запись каталога об одной копии. ID - имя локального файла
+// копии, LocalPath пуст после удаления локальной копии. Verified - итог
+// сверки с назначениями, Restored - итог последней проверки восстановлением.
+// Entropy - энтропия байтов в битах, Ratio - доля, до которой сжимаются
+// выборочные блоки; Anomalies - отклонения от истории задания, из-за
+// которых копия подозрительна. Закрепленная (Pinned) копия не удаляется
+// очисткой, пока ее не открепят
+type CatalogEntry struct {
+	ID          string        `json:"id"`
+	Job         string        `json:"job"`
+	Source      string        `json:"source"`
+	LocalPath   string        `json:"local_path,omitempty"`
+	Started     time.Time     `json:"started"`
+	Created     time.Time     `json:"created"`
+	Size        int64         `json:"size"`
+	Md5         string        `json:"md5"`
+	Sha256      string        `json:"sha256"`
+	Compression string        `json:"compression"`
+	Encryption  string        `json:"encryption"`
+	Copies      []CatalogCopy `json:"copies"`
+	Verified    string        `json:"verified,omitempty"`
+	VerifiedAt  time.Time     `json:"verified_at,omitempty"`
+	Restored    string        `json:"restored,omitempty"`
+	RestoredAt  time.Time     `json:"restored_at,omitempty"`
+	Entropy     float64       `json:"entropy,omitempty"`
+	Ratio       float64       `json:"ratio,omitempty"`
+	Suspicious  bool          `json:"suspicious,omitempty"`
+	Anomalies   []string      `json:"anomalies,omitempty"`
+	Pinned      bool          `json:"pinned,omitempty"`
+	PinnedAt    time.Time     `json:"pinned_at,omitempty"`
+	PinReason   string        `json:"pin_reason,omitempty"`
+}
+
+// CatalogCopy - копия записи каталога в одном назначении. Name - путь
+// копии относительно папки назначения
+type CatalogCopy struct {
+	Destination string    `json:"destination"`
+	Name        string    `json:"name,omitempty"`
+	RemotePath  string    `json:"remote_path"`
+	Status      string    `json:"status"`
+	Uploaded    time.Time `json:"uploaded,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Copy возвращает копию в назначении или nil
+func (e *CatalogEntry) Copy(destination string) *CatalogCopy {
+	for i := range e.Copies {
+		if e.Copies[i].Destination == destination {
+			return &e.Copies[i]
+		}
+	}
+
+	return nil
+}
+
+// RemoteName - путь копии относительно папки назначения. Записи, сделанные
+// до шаблонов путей, хранят только полный путь, и копия лежит в корне папки
+func (c CatalogCopy) RemoteName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+
+	return path.Base(filepath.ToSlash(c.RemotePath))
+}
+
+// SamePath сообщает, что пути указывают на один файл назначения: пути Yandex
+// Disk могут быть с префиксом disk: и ведущим слэшем
+func SamePath(a string, b string) bool {
+	return CleanPath(a) == CleanPath(b)
+}
+
+// CleanPath приводит путь файла к виду для сравнения: прямые слэши, без
+// префикса disk: и ведущего слэша
+func CleanPath(filePath string) string {
+	return path.Clean(strings.TrimPrefix(strings.TrimPrefix(filepath.ToSlash(filePath), "disk:"), "/"))
+}
+
+// IsServiceFile сообщает, что файл в папке назначения служебный и не
+// является копией
+func IsServiceFile(name string) bool {
+	return name == LeaseName || name == CatalogName
+}
@@ -30,3 +30,10 @@ func (d *Duration) UnmarshalJSON(b []byte) error {
 		return errors.New("invalid duration")
 	}
 }
+
+// MarshalJSON записывает длительность строкой вида "1h30m0s", которую
+// UnmarshalJSON читает обратно, поэтому вывод config show можно загрузить
+// как конфигурацию
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDurationJSON(t *testing.T) {
+	value := struct {
+		Timeout Duration  `json:"timeout"`
+		Retry   *Duration `json:"retry"`
+	}{
+		Timeout: Duration{Duration: 90 * time.Minute},
+		Retry:   &Duration{Duration: 5 * time.Second},
+	}
+
+	data, err := json.Marshal(value)
+
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	if string(data) != `{"timeout":"1h30m0s","retry":"5s"}` {
+		t.Errorf("json = %s, want durations as strings", data)
+	}
+
+	value.Timeout, value.Retry = Duration{}, nil
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if value.Timeout.Duration != 90*time.Minute || value.Retry.Duration != 5*time.Second {
+		t.Errorf("value = %+v, want round trip", value)
+	}
+}
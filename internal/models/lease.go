@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+const LeaseName = ".lease.json"
+
+// Lease - аренда папки назначения одним процессом. Пока аренда не истекла,
+// другие хосты не загружают копии в папку и не удаляют из нее устаревшие
+type Lease struct {
+	Owner   string    `json:"owner"`
+	Host    string    `json:"host"`
+	Pid     int       `json:"pid"`
+	Renewed time.Time `json:"renewed"`
+	Expires time.Time `json:"expires"`
+}
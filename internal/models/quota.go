@@ -0,0 +1,8 @@
+package models
+
+// Quota - место на диске аккаунта в байтах. Trash - место, занятое корзиной
+type Quota struct {
+	Total int64 `json:"total"`
+	Used  int64 `json:"used"`
+	Trash int64 `json:"trash"`
+}
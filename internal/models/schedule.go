@@ -0,0 +1,66 @@
+package models
+
+import (
+	"github.com/robfig/cron/v3"
+	"time"
+)
+
+const (
+	CatchUpSkip = "skip"
+	CatchUpRun  = "run"
+)
+
+// Schedule - расписание задания: cron-выражение или интервал
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+func (s intervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(s.interval)
+}
+
+// IsScheduled сообщает, задано ли у задания расписание для режима демона
+func (f Files) IsScheduled() bool {
+	return f.Schedule != "" || f.Interval.Duration > 0
+}
+
+// GetSchedule разбирает расписание задания. Cron-выражение задается в
+// стандартном формате из пяти полей или дескриптором вида @daily
+func (f Files) GetSchedule() (Schedule, error) {
+	if f.Interval.Duration > 0 {
+		return intervalSchedule{interval: f.Interval.Duration}, nil
+	}
+
+	return cron.ParseStandard(f.Schedule)
+}
+
+// IsScheduled сообщает, задано ли расписание проверки восстановлением
+func (t TestRestore) IsScheduled() bool {
+	return t.Schedule != "" || t.Interval.Duration > 0
+}
+
+// GetSchedule разбирает расписание проверки восстановлением
+func (t TestRestore) GetSchedule() (Schedule, error) {
+	if t.Interval.Duration > 0 {
+		return intervalSchedule{interval: t.Interval.Duration}, nil
+	}
+
+	return cron.ParseStandard(t.Schedule)
+}
+
+// JobState - состояние задания в режиме демона. StaleNotified - время
+// уведомления об устаревшей копии, повторно оно не отправляется до новой
+// успешной копии. TokenNotified - последнее сообщенное состояние токена,
+// заполняется только в состоянии аккаунта
+type JobState struct {
+	LastRun       time.Time `json:"last_run"`
+	LastSuccess   time.Time `json:"last_success,omitempty"`
+	LastStatus    string    `json:"last_status"`
+	NextRun       time.Time `json:"next_run"`
+	StaleNotified time.Time `json:"stale_notified,omitempty"`
+	TokenNotified string    `json:"token_notified,omitempty"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const redacted = "***"
+
+var secretPrefixes = []string{"env:", "file:", "vault:"}
+
+// Secret - учетные данные в конфигурации. Значение задается строкой или
+// ссылкой env:NAME, file:/path или vault:name, которая подставляется при
+// Setting.ResolveSecrets. При логировании и выводе значение всегда скрыто
+type Secret struct {
+	Ref   string
+	value string
+}
+
+func NewSecret(value string) Secret {
+	return Secret{Ref: value, value: value}
+}
+
+func (s *Secret) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &s.Ref); err != nil {
+		return err
+	}
+
+	s.value = ""
+
+	if !s.IsRef() {
+		s.value = s.Ref
+	}
+
+	return nil
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s Secret) String() string {
+	if s.Ref == "" {
+		return ""
+	}
+
+	return redacted
+}
+
+func (s Secret) Value() string {
+	return s.value
+}
+
+func (s Secret) IsRef() bool {
+	for _, prefix := range secretPrefixes {
+		if strings.HasPrefix(s.Ref, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resolve подставляет значение ссылки через resolve
+func (s *Secret) Resolve(resolve func(ref string) (string, error)) error {
+	if !s.IsRef() {
+		return nil
+	}
+
+	value, err := resolve(s.Ref)
+
+	if err != nil {
+		return err
+	}
+
+	s.value = value
+
+	return nil
+}
@@ -1,64 +1,612 @@
 package models
 
 import (
-	"encoding/json"
-	"errors"
-	"github.com/go-playground/validator/v10"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+const (
+	DestinationYandex = "yandex"
+	DestinationNas    = "nas"
+)
+
+// Режимы удаления копий в назначениях yandex
+const (
+	DeletePermanent = "permanent"
+	DeleteTrash     = "trash"
 )
 
 type Setting struct {
-	Verbose bool    `json:"verbose" validate:"required"`
-	Files   []Files `json:"files" validate:"required"`
-	Backup  Backup  `json:"backup" validate:"required"`
-	Yandex  Yandex  `json:"yandex" validate:"required"`
+	Verbose      bool          `json:"verbose"`
+	Files        []Files       `json:"files" validate:"required,dive"`
+	Backup       Backup        `json:"backup"`
+	Yandex       Yandex        `json:"yandex"`
+	Nas          Nas           `json:"nas"`
+	Accounts     []Account     `json:"accounts" validate:"dive"`
+	Destinations []Destination `json:"destinations" validate:"dive"`
+	Credentials  string        `json:"credentials"`
+	Vault        Vault         `json:"vault"`
+	Daemon       Daemon        `json:"daemon"`
+	Lock         Lock          `json:"lock"`
+	Verify       TestRestore   `json:"verify"`
+	Notify       Notify        `json:"notify"`
+	Metrics      Metrics       `json:"metrics"`
+	Anomaly      Anomaly       `json:"anomaly"`
+	Quota        QuotaPolicy   `json:"quota"`
+}
+
+// Daemon - настройки режима демона. State - файл с временем последнего и
+// следующего запуска заданий, из него читает команда status. Retry - период
+// повтора неподтвержденных загрузок, Watchdog - период проверки возраста
+// последних успешных копий
+type Daemon struct {
+	State    string   `json:"state"`
+	Retry    Duration `json:"retry" validate:"omitempty,gt=0"`
+	Watchdog Duration `json:"watchdog" validate:"omitempty,gt=0"`
+}
+
+// Anomaly - поиск подозрительных копий по истории задания: размер, степень
+// сжатия и энтропия байтов новой копии сравниваются с медианой последних
+// History нормальных копий. Size и Ratio - допустимые отклонения в долях от
+// медианы, Entropy - в битах на байт. Пока у задания есть подозрительные
+// копии, очистка не удаляет его последние Keep нормальных копий
+type Anomaly struct {
+	Disabled bool    `json:"disabled"`
+	History  int     `json:"history" validate:"omitempty,gt=0"`
+	Size     float64 `json:"size" validate:"omitempty,gt=0"`
+	Ratio    float64 `json:"ratio" validate:"omitempty,gt=0"`
+	Entropy  float64 `json:"entropy" validate:"omitempty,gt=0,lte=8"`
+	Keep     int     `json:"keep" validate:"omitempty,gt=0"`
+}
+
+// QuotaPolicy - проверка места на Yandex Disk перед каждым запуском. Warn -
+// доли занятого места, при достижении которых выводится предупреждение.
+// Если планируемые загрузки не помещаются, EmptyTrash удаляет из корзины
+// файлы папок назначений, а Prune - самые старые копии, кроме последних
+// count копий каждого задания и защищенных копий
+type QuotaPolicy struct {
+	Warn       []float64 `json:"warn" validate:"dive,gt=0,lte=1"`
+	EmptyTrash bool      `json:"empty_trash"`
+	Prune      bool      `json:"prune"`
+}
+
+// Metrics - метрики Prometheus. Listen - адрес HTTP-сервера метрик в режиме
+// демона, например :9310; Textfile - файл .prom для textfile collector
+// node_exporter, который записывается после разовых команд
+type Metrics struct {
+	Listen   string `json:"listen" validate:"omitempty,hostname_port"`
+	Textfile string `json:"textfile" validate:"omitempty,endswith=.prom"`
+}
+
+// TestRestore - проверка копий восстановлением. Без Schedule и Interval
+// проверка выполняется только командой verify --restore. Count - число
+// копий за запуск, Random - случайные копии вместо самых давно не проверенных
+type TestRestore struct {
+	Schedule string   `json:"schedule" validate:"omitempty,cron,excluded_with=Interval"`
+	Interval Duration `json:"interval" validate:"omitempty,gt=0"`
+	Count    int      `json:"count" validate:"omitempty,gt=0"`
+	Random   bool     `json:"random"`
+}
+
+// Notify - уведомления об итогах запусков. Policy - политика по умолчанию
+// для заданий без своей: always, failure, recovery или never
+type Notify struct {
+	Policy   string    `json:"policy" validate:"omitempty,oneof=always failure recovery never"`
+	Smtp     Smtp      `json:"smtp"`
+	Telegram Telegram  `json:"telegram"`
+	Webhooks []Webhook `json:"webhooks" validate:"dive"`
+	Retry    Retry     `json:"retry"`
+}
+
+// Telegram - отправка уведомлений через Telegram Bot API. URL - адрес API,
+// его можно заменить на тестовый сервер. Без Token уведомления не отправляются
+type Telegram struct {
+	Token   Secret   `json:"token"`
+	ChatIDs []string `json:"chat_ids" validate:"required_with=Token"`
+	URL     string   `json:"url" validate:"omitempty,url"`
+	Timeout Duration `json:"timeout" validate:"omitempty,gt=0"`
+}
+
+// Webhook - отправка отчета POST-запросом. Template - шаблон text/template
+// тела запроса, по умолчанию отчет в JSON. При заданном Secret тело
+// подписывается HMAC-SHA256 в заголовке SignatureHeader
+type Webhook struct {
+	Name            string            `json:"name" validate:"required"`
+	URL             string            `json:"url" validate:"required,url"`
+	Headers         map[string]Secret `json:"headers"`
+	Secret          Secret            `json:"secret"`
+	SignatureHeader string            `json:"signature_header"`
+	Template        string            `json:"template"`
+	Timeout         Duration          `json:"timeout" validate:"omitempty,gt=0"`
+}
+
+// Retry - повтор отправки уведомлений. Interval - минимальный интервал между
+// сообщениями одного канала, чтобы не упираться в ограничения API
+type Retry struct {
+	Attempts int      `json:"attempts" validate:"omitempty,gt=0"`
+	Delay    Duration `json:"delay" validate:"omitempty,gt=0"`
+	Interval Duration `json:"interval" validate:"omitempty,gt=0"`
+}
+
+// Smtp - отправка уведомлений по почте. Security: starttls, tls (неявный
+// TLS, обычно порт 465) или none. Без Host почта не отправляется
+type Smtp struct {
+	Host       string   `json:"host"`
+	Port       int      `json:"port" validate:"omitempty,gt=0,lte=65535"`
+	Security   string   `json:"security" validate:"omitempty,oneof=starttls tls none"`
+	Username   string   `json:"username"`
+	Password   Secret   `json:"password"`
+	From       string   `json:"from" validate:"required_with=Host,omitempty,email"`
+	To         []string `json:"to" validate:"required_with=Host,dive,email"`
+	Timeout    Duration `json:"timeout" validate:"omitempty,gt=0"`
+	SkipVerify bool     `json:"skip_verify"`
 }
 
+// Lock - блокировки заданий. Dir - директория файлов блокировок. Remote
+// включает аренду папок назначений на время Lease, чтобы несколько хостов с
+// общей папкой не удаляли копии друг друга
+type Lock struct {
+	Dir    string   `json:"dir"`
+	Remote bool     `json:"remote"`
+	Lease  Duration `json:"lease" validate:"omitempty,gt=0"`
+}
+
+// Files - задание копирования. MaxAge - допустимый возраст последней
+// успешной копии, после которого отправляется уведомление; Ping - адрес
+// проверки в стиле healthchecks.io, который получает start, success и fail.
+// RemotePath - шаблон пути копии относительно папки назначения
 type Files struct {
-	Path string `json:"path" validate:"required"`
-	Name string `json:"name" validate:"required"`
+	Path         string   `json:"path" validate:"required"`
+	Name         string   `json:"name" validate:"required,fsname"`
+	Account      string   `json:"account"`
+	Destinations []string `json:"destinations"`
+	Schedule     string   `json:"schedule" validate:"omitempty,cron,excluded_with=Interval"`
+	Interval     Duration `json:"interval" validate:"omitempty,gt=0"`
+	Jitter       Duration `json:"jitter" validate:"omitempty,gt=0"`
+	CatchUp      string   `json:"catch_up" validate:"omitempty,oneof=skip run"`
+	Priority     int      `json:"priority"`
+	Notify       string   `json:"notify" validate:"omitempty,oneof=always failure recovery never"`
+	MaxAge       Duration `json:"max_age" validate:"omitempty,gt=0"`
+	Ping         Secret   `json:"ping"`
+	RemotePath   string   `json:"remote_path"`
 }
 
+// Destination - место хранения копий со своей политикой хранения.
+// Пустой Expired означает срок хранения из секции backup.
+// Для назначений yandex Account указывает аккаунт, пустой Dir - папку аккаунта,
+// Delete - удаление устаревших копий безвозвратно или в корзину, TrashGrace -
+// срок, после которого копии назначения удаляются из корзины
+type Destination struct {
+	Name       string   `json:"name" validate:"required,fsname"`
+	Type       string   `json:"type" validate:"required,oneof=yandex nas"`
+	Account    string   `json:"account"`
+	Dir        string   `json:"dir"`
+	Extension  bool     `json:"extension"`
+	Required   bool     `json:"required"`
+	Expired    Duration `json:"expired" validate:"omitempty,gt=0"`
+	Delete     string   `json:"delete" validate:"omitempty,oneof=permanent trash"`
+	TrashGrace Duration `json:"trash_grace" validate:"omitempty,gt=0"`
+}
+
+// Backup - локальные копии и их хранение. CopyWorkers ограничивает число
+// одновременных локальных копирований, UploadWorkers - число одновременных
+// загрузок в назначения по всем заданиям. Queue - журнал неподтвержденных
+// загрузок, Catalog - каталог всех копий, оба по умолчанию рядом с Dir
 type Backup struct {
-	Dir       string   `json:"dir" validate:"required"`
-	Retention int      `json:"count" validate:"required"`
-	Expired   Duration `json:"expired" validate:"required"`
+	Dir           string   `json:"dir" validate:"required"`
+	Retention     int      `json:"count" validate:"required,gt=0"`
+	Expired       Duration `json:"expired" validate:"required,gt=0"`
+	CopyWorkers   int      `json:"copy_workers" validate:"omitempty,gt=0"`
+	UploadWorkers int      `json:"upload_workers" validate:"omitempty,gt=0"`
+	Queue         string   `json:"queue"`
+	Catalog       string   `json:"catalog"`
 }
 
+// Yandex - аккаунт и назначение по умолчанию. Delete и TrashGrace
+// действуют на назначения, созданные без секции destinations
 type Yandex struct {
-	Timeout   Duration `json:"timeout" validate:"required"`
-	Token     string   `json:"token" validate:"required"`
-	Dir       string   `json:"dir" validate:"required"`
-	Extension bool     `json:"extension" validate:"required"`
+	Timeout      Duration `json:"timeout" validate:"omitempty,gt=0"`
+	Token        Secret   `json:"token"`
+	Dir          string   `json:"dir"`
+	Extension    bool     `json:"extension"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret Secret   `json:"client_secret"`
+	Delete       string   `json:"delete" validate:"omitempty,oneof=permanent trash"`
+	TrashGrace   Duration `json:"trash_grace" validate:"omitempty,gt=0"`
+}
+
+// Account - аккаунт Yandex Disk со своим токеном, папкой и таймаутом.
+// Токен можно не указывать, если он получен командой login и лежит в файле
+// credentials; ClientID и ClientSecret нужны для login и обновления токена
+type Account struct {
+	Name          string   `json:"name" validate:"required,fsname"`
+	Token         Secret   `json:"token"`
+	Dir           string   `json:"dir"`
+	Timeout       Duration `json:"timeout" validate:"omitempty,gt=0"`
+	ClientID      string   `json:"client_id"`
+	ClientSecret  Secret   `json:"client_secret"`
+	ExpiryWarning Duration `json:"expiry_warning" validate:"omitempty,gt=0"`
+}
+
+// Vault - зашифрованное хранилище секретов для ссылок vault:name.
+// Мастер-ключ по умолчанию берется из переменной окружения YD_MASTER_KEY
+type Vault struct {
+	Path string `json:"path"`
+	Key  Secret `json:"key"`
 }
 
-type IError struct {
-	Field string
-	Tag   string
-	Value string
+type Nas struct {
+	Dir       string `json:"dir"`
+	Extension bool   `json:"extension"`
+}
+
+const (
+	DefaultBackupDir     = "./backup"
+	DefaultYandexDir     = "backup"
+	DefaultTimeout       = 2 * time.Hour
+	DefaultCredentials   = "./config/credentials.json"
+	DefaultExpiryWarning = 7 * 24 * time.Hour
+	DefaultVaultKey      = "env:YD_MASTER_KEY"
+	DefaultState         = "./config/state.json"
+	DefaultLockDir       = "./config/locks"
+	DefaultLease         = 10 * time.Minute
+	DefaultCopyWorkers   = 1
+	DefaultUploadWorkers = 2
+	DefaultRetry         = 5 * time.Minute
+	DefaultWatchdog      = 15 * time.Minute
+	DefaultHistory       = 7
+	DefaultSizeDeviation = 0.5
+	DefaultRatioChange   = 0.5
+	DefaultEntropyChange = 1.0
+	DefaultKeepGood      = 3
+	DefaultSmtpTimeout   = 30 * time.Second
+	DefaultTelegramURL   = "https://api.telegram.org"
+	DefaultNotifyTimeout = 30 * time.Second
+	DefaultNotifyRetries = 3
+	DefaultNotifyDelay   = 5 * time.Second
+	DefaultNotifyRate    = time.Second
+	DefaultSignature     = "X-Signature-256"
+)
+
+// DefaultQuotaWarn - доли занятого места Disk для предупреждений
+var DefaultQuotaWarn = []float64{0.8, 0.95}
+
+// Политики уведомлений
+const (
+	NotifyAlways   = "always"
+	NotifyFailure  = "failure"
+	NotifyRecovery = "recovery"
+	NotifyNever    = "never"
+)
+
+// Режимы защиты соединения SMTP
+const (
+	SmtpStartTLS = "starttls"
+	SmtpTLS      = "tls"
+	SmtpNone     = "none"
+)
+
+// SetDefaults заполняет необязательные поля значениями по умолчанию
+func (s *Setting) SetDefaults() {
+	if s.Backup.Dir == "" {
+		s.Backup.Dir = DefaultBackupDir
+	}
+
+	if s.Backup.Queue == "" {
+		s.Backup.Queue = filepath.Clean(s.Backup.Dir) + ".queue.json"
+	}
+
+	if s.Backup.Catalog == "" {
+		s.Backup.Catalog = filepath.Clean(s.Backup.Dir) + ".catalog.json"
+	}
+
+	if s.Backup.CopyWorkers == 0 {
+		s.Backup.CopyWorkers = DefaultCopyWorkers
+	}
+
+	if s.Backup.UploadWorkers == 0 {
+		s.Backup.UploadWorkers = DefaultUploadWorkers
+	}
+
+	if s.Yandex.Dir == "" {
+		s.Yandex.Dir = DefaultYandexDir
+	}
+
+	if s.Yandex.Timeout.Duration == 0 {
+		s.Yandex.Timeout.Duration = DefaultTimeout
+	}
+
+	if s.Credentials == "" {
+		s.Credentials = DefaultCredentials
+	}
+
+	if s.Daemon.State == "" {
+		s.Daemon.State = DefaultState
+	}
+
+	if s.Daemon.Retry.Duration == 0 {
+		s.Daemon.Retry.Duration = DefaultRetry
+	}
+
+	if s.Daemon.Watchdog.Duration == 0 {
+		s.Daemon.Watchdog.Duration = DefaultWatchdog
+	}
+
+	if s.Anomaly.History == 0 {
+		s.Anomaly.History = DefaultHistory
+	}
+
+	if s.Anomaly.Size == 0 {
+		s.Anomaly.Size = DefaultSizeDeviation
+	}
+
+	if s.Anomaly.Ratio == 0 {
+		s.Anomaly.Ratio = DefaultRatioChange
+	}
+
+	if s.Anomaly.Entropy == 0 {
+		s.Anomaly.Entropy = DefaultEntropyChange
+	}
+
+	if s.Anomaly.Keep == 0 {
+		s.Anomaly.Keep = DefaultKeepGood
+	}
+
+	if s.Quota.Warn == nil {
+		s.Quota.Warn = append([]float64(nil), DefaultQuotaWarn...)
+	}
+
+	if s.Notify.Policy == "" {
+		s.Notify.Policy = NotifyFailure
+	}
+
+	if s.Notify.Smtp.Security == "" {
+		s.Notify.Smtp.Security = SmtpStartTLS
+	}
+
+	if s.Notify.Smtp.Port == 0 {
+		switch s.Notify.Smtp.Security {
+		case SmtpTLS:
+			s.Notify.Smtp.Port = 465
+		case SmtpNone:
+			s.Notify.Smtp.Port = 25
+		default:
+			s.Notify.Smtp.Port = 587
+		}
+	}
+
+	if s.Notify.Smtp.Timeout.Duration == 0 {
+		s.Notify.Smtp.Timeout.Duration = DefaultSmtpTimeout
+	}
+
+	if s.Notify.Telegram.URL == "" {
+		s.Notify.Telegram.URL = DefaultTelegramURL
+	}
+
+	if s.Notify.Telegram.Timeout.Duration == 0 {
+		s.Notify.Telegram.Timeout.Duration = DefaultNotifyTimeout
+	}
+
+	for i := range s.Notify.Webhooks {
+		if s.Notify.Webhooks[i].Timeout.Duration == 0 {
+			s.Notify.Webhooks[i].Timeout.Duration = DefaultNotifyTimeout
+		}
+
+		if s.Notify.Webhooks[i].SignatureHeader == "" {
+			s.Notify.Webhooks[i].SignatureHeader = DefaultSignature
+		}
+	}
+
+	if s.Notify.Retry.Attempts == 0 {
+		s.Notify.Retry.Attempts = DefaultNotifyRetries
+	}
+
+	if s.Notify.Retry.Delay.Duration == 0 {
+		s.Notify.Retry.Delay.Duration = DefaultNotifyDelay
+	}
+
+	if s.Notify.Retry.Interval.Duration == 0 {
+		s.Notify.Retry.Interval.Duration = DefaultNotifyRate
+	}
+
+	if s.Verify.Count == 0 {
+		s.Verify.Count = 1
+	}
+
+	if s.Lock.Dir == "" {
+		s.Lock.Dir = DefaultLockDir
+	}
+
+	if s.Lock.Lease.Duration == 0 {
+		s.Lock.Lease.Duration = DefaultLease
+	}
+
+	for i := range s.Files {
+		if s.Files[i].CatchUp == "" {
+			s.Files[i].CatchUp = CatchUpSkip
+		}
+
+		if s.Files[i].Notify == "" {
+			s.Files[i].Notify = s.Notify.Policy
+		}
+	}
 }
 
-func (s *Setting) Validate() error {
-	v := validator.New()
-	err := v.Struct(s)
-	var errs []IError
+// GetAccounts возвращает аккаунты Yandex Disk. Секция yandex, если в ней
+// задан токен или client_id, превращается в аккаунт с именем yandex
+func (s *Setting) GetAccounts() []Account {
+	var accounts []Account
 
-	if err != nil {
-		for _, err := range err.(validator.ValidationErrors) {
-			var el IError
-			el.Field = err.Field()
-			el.Tag = err.Tag()
-			el.Value = err.Param()
-			errs = append(errs, el)
+	if (s.Yandex.Token.Ref != "" || s.Yandex.ClientID != "") && s.getAccount(DestinationYandex) == nil {
+		accounts = append(accounts, Account{
+			Name:         DestinationYandex,
+			Token:        s.Yandex.Token,
+			Dir:          s.Yandex.Dir,
+			Timeout:      s.Yandex.Timeout,
+			ClientID:     s.Yandex.ClientID,
+			ClientSecret: s.Yandex.ClientSecret,
+		})
+	}
+
+	accounts = append(accounts, s.Accounts...)
+
+	for i := range accounts {
+		if accounts[i].Timeout.Duration == 0 {
+			accounts[i].Timeout = s.Yandex.Timeout
 		}
 
-		message, err := json.Marshal(errs)
+		if accounts[i].Dir == "" {
+			accounts[i].Dir = s.Yandex.Dir
+		}
 
-		if err != nil {
-			return err
+		if accounts[i].ExpiryWarning.Duration == 0 {
+			accounts[i].ExpiryWarning.Duration = DefaultExpiryWarning
 		}
+	}
+
+	return accounts
+}
+
+func (s *Setting) GetCredentials() string {
+	if s.Credentials == "" {
+		return DefaultCredentials
+	}
+
+	return s.Credentials
+}
+
+func (s *Setting) getAccount(name string) *Account {
+	for i := range s.Accounts {
+		if s.Accounts[i].Name == name {
+			return &s.Accounts[i]
+		}
+	}
+
+	return nil
+}
 
-		return errors.New(string(message))
+// GetDestinations возвращает список назначений из конфигурации. Если список
+// не задан, назначения собираются из аккаунтов и секции nas: по одному
+// назначению на аккаунт с именем аккаунта
+func (s *Setting) GetDestinations() []Destination {
+	var destinations []Destination
+
+	accounts := s.GetAccounts()
+
+	if len(s.Destinations) > 0 {
+		destinations = append(destinations, s.Destinations...)
 	} else {
-		return nil
+		for _, account := range accounts {
+			destinations = append(destinations, Destination{
+				Name:       account.Name,
+				Type:       DestinationYandex,
+				Account:    account.Name,
+				Extension:  s.Yandex.Extension,
+				Required:   true,
+				Delete:     s.Yandex.Delete,
+				TrashGrace: s.Yandex.TrashGrace,
+			})
+		}
+
+		if s.Nas.Dir != "" {
+			destinations = append(destinations, Destination{
+				Name:      DestinationNas,
+				Type:      DestinationNas,
+				Dir:       s.Nas.Dir,
+				Extension: s.Nas.Extension,
+				Required:  true,
+			})
+		}
 	}
+
+	for i := range destinations {
+		if destinations[i].Expired.Duration == 0 {
+			destinations[i].Expired = s.Backup.Expired
+		}
+
+		if destinations[i].Type != DestinationYandex {
+			continue
+		}
+
+		if destinations[i].Delete == "" {
+			destinations[i].Delete = DeletePermanent
+		}
+
+		if destinations[i].Account == "" {
+			destinations[i].Account = DestinationYandex
+		}
+
+		if destinations[i].Dir == "" {
+			for _, account := range accounts {
+				if account.Name == destinations[i].Account {
+					destinations[i].Dir = account.Dir
+				}
+			}
+		}
+	}
+
+	return destinations
+}
+
+func (s *Setting) GetVaultKey() Secret {
+	if s.Vault.Key.Ref == "" {
+		var key Secret
+		key.Ref = DefaultVaultKey
+		return key
+	}
+
+	return s.Vault.Key
+}
+
+// ResolveSecrets подставляет значения всех ссылок на секреты в конфигурации
+func (s *Setting) ResolveSecrets(resolve func(ref string) (string, error)) error {
+	type field struct {
+		path   string
+		secret *Secret
+	}
+
+	fields := []field{
+		{"yandex.token", &s.Yandex.Token},
+		{"yandex.client_secret", &s.Yandex.ClientSecret},
+	}
+
+	fields = append(fields,
+		field{"notify.smtp.password", &s.Notify.Smtp.Password},
+		field{"notify.telegram.token", &s.Notify.Telegram.Token},
+	)
+
+	for i := range s.Notify.Webhooks {
+		fields = append(fields, field{fmt.Sprintf("notify.webhooks[%d].secret", i), &s.Notify.Webhooks[i].Secret})
+	}
+
+	for i := range s.Files {
+		fields = append(fields, field{fmt.Sprintf("files[%d].ping", i), &s.Files[i].Ping})
+	}
+
+	for i := range s.Accounts {
+		fields = append(fields,
+			field{fmt.Sprintf("accounts[%d].token", i), &s.Accounts[i].Token},
+			field{fmt.Sprintf("accounts[%d].client_secret", i), &s.Accounts[i].ClientSecret},
+		)
+	}
+
+	for _, f := range fields {
+		if err := f.secret.Resolve(resolve); err != nil {
+			return fmt.Errorf("%s: %v", f.path, err)
+		}
+	}
+
+	// Значения map не адресуемы, поэтому заголовки разрешаются через копию
+	for i, webhook := range s.Notify.Webhooks {
+		for name, secret := range webhook.Headers {
+			if err := secret.Resolve(resolve); err != nil {
+				return fmt.Errorf("notify.webhooks[%d].headers.%s: %v", i, name, err)
+			}
+
+			webhook.Headers[name] = secret
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// remotePlaceholder - подстановка шаблона пути копии вида {job}
+var remotePlaceholder = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// RemoteValues - значения подстановок шаблона пути копии в назначении.
+// Name - имя копии без расширения, Ext - расширение исходного файла без
+// точки, File - имя копии в назначении без шаблона, Created - время копии
+type RemoteValues struct {
+	Job     string
+	Host    string
+	Name    string
+	Ext     string
+	File    string
+	Created time.Time
+}
+
+func (v RemoteValues) placeholders() map[string]string {
+	return map[string]string{
+		"job":     v.Job,
+		"host":    v.Host,
+		"name":    v.Name,
+		"ext":     v.Ext,
+		"file":    v.File,
+		"year":    v.Created.Format("2006"),
+		"month":   v.Created.Format("01"),
+		"day":     v.Created.Format("02"),
+		"hour":    v.Created.Format("15"),
+		"minute":  v.Created.Format("04"),
+		"weekday": strings.ToLower(v.Created.Weekday().String()),
+	}
+}
+
+// RenderRemotePath подставляет значения в шаблон пути копии относительно
+// папки назначения
+func RenderRemotePath(template string, values RemoteValues) string {
+	placeholders := values.placeholders()
+
+	return remotePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		return placeholders[strings.Trim(placeholder, "{}")]
+	})
+}
+
+// runPlaceholders - подстановки, уникальные для каждой копии: оба имени
+// содержат время создания копии
+var runPlaceholders = []string{"{name}", "{file}"}
+
+// CheckRemotePath проверяет шаблон пути копии: известные подстановки,
+// относительный путь без пустых частей и переходов вверх, а в имени файла -
+// подстановку, уникальную для каждой копии
+func CheckRemotePath(template string) error {
+	placeholders := RemoteValues{}.placeholders()
+
+	for _, match := range remotePlaceholder.FindAllStringSubmatch(template, -1) {
+		if _, ok := placeholders[match[1]]; !ok {
+			return fmt.Errorf("unknown placeholder {%s}", match[1])
+		}
+	}
+
+	if strings.HasPrefix(template, "/") || strings.Contains(template, "\\") || strings.Contains(template, ":") {
+		return fmt.Errorf("must be a relative path with / separators")
+	}
+
+	for _, part := range strings.Split(template, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("must not contain empty, . or .. parts")
+		}
+	}
+
+	base := path.Base(template)
+
+	for _, placeholder := range runPlaceholders {
+		if strings.Contains(base, placeholder) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("file name must contain %s, otherwise backups overwrite each other",
+		strings.Join(runPlaceholders, " or "))
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckRemotePath(t *testing.T) {
+	valid := []string{
+		"{file}",
+		"{job}/{year}/{month}/{file}",
+		"{host}/{job}/{name}.{ext}",
+		"{job}/{weekday}/{name}",
+	}
+
+	for _, template := range valid {
+		if err := CheckRemotePath(template); err != nil {
+			t.Errorf("CheckRemotePath(%q) = %v, want valid", template, err)
+		}
+	}
+
+	invalid := map[string]string{
+		"{job}/{host}.{ext}":     "{name} or {file}",
+		"{job}/{weekday}.{ext}":  "{name} or {file}",
+		"{name}/backup.zip":      "{name} or {file}",
+		"{job}/{year}/{minute}":  "{name} or {file}",
+		"{job}/{unknown}/{file}": "unknown placeholder",
+		"/{job}/{file}":          "relative",
+		"{job}/../{file}":        "..",
+		"{job}//{file}":          "empty",
+	}
+
+	for template, want := range invalid {
+		err := CheckRemotePath(template)
+
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("CheckRemotePath(%q) = %v, want error with %q", template, err, want)
+		}
+	}
+}
+
+func TestRenderRemotePath(t *testing.T) {
+	values := RemoteValues{
+		Job:     "Trade",
+		Host:    "srv",
+		Name:    "Trade_20261001020000_1Cv8",
+		Ext:     "1CD",
+		File:    "Trade_20261001020000_1Cv8.zip",
+		Created: time.Date(2026, 10, 1, 2, 0, 0, 0, time.Local),
+	}
+
+	if name := RenderRemotePath("{job}/{year}/{month}/{weekday}/{file}", values); name != "Trade/2026/10/thursday/Trade_20261001020000_1Cv8.zip" {
+		t.Errorf("name = %q", name)
+	}
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Upload - загрузка локальной копии в назначение, которая еще не
+// подтверждена. Path - путь к локальной копии
+type Upload struct {
+	Path        string    `json:"path"`
+	Job         string    `json:"job"`
+	Destination string    `json:"destination"`
+	Created     time.Time `json:"created"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastAttempt time.Time `json:"last_attempt,omitempty"`
+}
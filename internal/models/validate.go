@@ -0,0 +1,303 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/robfig/cron/v3"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Символы и имена, недопустимые в именах файлов Windows и Unix
+const unsafeChars = `/\:*?"<>|`
+
+var reservedNames = []string{"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9"}
+
+type IError struct {
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Value   string `json:"value"`
+	Message string `json:"message"`
+}
+
+// ValidationError - все ошибки проверки конфигурации с JSON-путями полей
+type ValidationError []IError
+
+func (e ValidationError) Error() string {
+	var lines []string
+
+	for _, err := range e {
+		lines = append(lines, fmt.Sprintf("%s: %s", err.Field, err.Message))
+	}
+
+	return strings.Join(lines, "; ")
+}
+
+func (s *Setting) Validate() error {
+	v := validator.New()
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+		if name == "-" {
+			return ""
+		}
+
+		return name
+	})
+
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		return field.Interface().(Duration).Duration.Nanoseconds()
+	}, Duration{})
+
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		return field.Interface().(Secret).Ref
+	}, Secret{})
+
+	if err := v.RegisterValidation("fsname", func(fl validator.FieldLevel) bool {
+		return IsSafeName(fl.Field().String())
+	}); err != nil {
+		return err
+	}
+
+	if err := v.RegisterValidation("cron", func(fl validator.FieldLevel) bool {
+		_, err := cron.ParseStandard(fl.Field().String())
+		return err == nil
+	}); err != nil {
+		return err
+	}
+
+	var errs ValidationError
+
+	err := v.Struct(s)
+
+	var validationErrors validator.ValidationErrors
+
+	if errors.As(err, &validationErrors) {
+		for _, err := range validationErrors {
+			errs = append(errs, IError{
+				Field:   jsonPath(err.Namespace()),
+				Tag:     err.Tag(),
+				Value:   err.Param(),
+				Message: message(err),
+			})
+		}
+	} else if err != nil {
+		return err
+	}
+
+	errs = append(errs, s.validateReferences()...)
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// validateReferences проверяет то, что не выражается тегами: уникальность
+// имен, доступность исходных файлов и ссылки на аккаунты и назначения
+func (s *Setting) validateReferences() ValidationError {
+	var errs ValidationError
+
+	add := func(field string, tag string, value string, message string) {
+		errs = append(errs, IError{Field: field, Tag: tag, Value: value, Message: message})
+	}
+
+	accounts := make(map[string]bool)
+
+	for _, account := range s.GetAccounts() {
+		accounts[account.Name] = true
+	}
+
+	destinations := make(map[string]bool)
+
+	for _, destination := range s.GetDestinations() {
+		destinations[destination.Name] = true
+	}
+
+	if len(destinations) == 0 {
+		add("destinations", "required", "", "no destinations: set yandex.token, accounts, nas.dir or destinations")
+	}
+
+	names := make(map[string]int)
+
+	for i, account := range s.Accounts {
+		field := fmt.Sprintf("accounts[%d]", i)
+
+		if j, ok := names[account.Name]; ok {
+			add(field+".name", "unique", account.Name, fmt.Sprintf("duplicates accounts[%d].name", j))
+		}
+
+		names[account.Name] = i
+
+		if account.Token.Ref == "" && account.ClientID == "" {
+			add(field+".token", "required", "", "is required unless client_id is set for login")
+		}
+	}
+
+	names = make(map[string]int)
+
+	for i, destination := range s.Destinations {
+		field := fmt.Sprintf("destinations[%d]", i)
+
+		if j, ok := names[destination.Name]; ok {
+			add(field+".name", "unique", destination.Name, fmt.Sprintf("duplicates destinations[%d].name", j))
+		}
+
+		names[destination.Name] = i
+
+		switch destination.Type {
+		case DestinationYandex:
+			account := destination.Account
+
+			if account == "" {
+				account = DestinationYandex
+			}
+
+			if !accounts[account] {
+				add(field+".account", "exists", account, fmt.Sprintf("unknown account %s", account))
+			}
+		case DestinationNas:
+			if destination.Dir == "" {
+				add(field+".dir", "required", "", "is required for nas destination")
+			}
+
+			if destination.Delete != "" || destination.TrashGrace.Duration != 0 {
+				add(field+".delete", "yandex", destination.Delete, "trash is supported only for yandex destination")
+			}
+		}
+	}
+
+	names = make(map[string]int)
+
+	for i, files := range s.Files {
+		field := fmt.Sprintf("files[%d]", i)
+
+		if j, ok := names[files.Name]; ok {
+			add(field+".name", "unique", files.Name, fmt.Sprintf("duplicates files[%d].name", j))
+		}
+
+		names[files.Name] = i
+
+		if files.Path != "" {
+			if err := checkReadable(files.Path); err != nil {
+				add(field+".path", "readable", files.Path, err.Error())
+			}
+		}
+
+		if files.Account != "" && !accounts[files.Account] {
+			add(field+".account", "exists", files.Account, fmt.Sprintf("unknown account %s", files.Account))
+		}
+
+		if files.RemotePath != "" {
+			if err := CheckRemotePath(files.RemotePath); err != nil {
+				add(field+".remote_path", "template", files.RemotePath, err.Error())
+			}
+		}
+
+		for j, name := range files.Destinations {
+			if !destinations[name] {
+				add(fmt.Sprintf("%s.destinations[%d]", field, j), "exists", name, fmt.Sprintf("unknown destination %s", name))
+			}
+		}
+	}
+
+	return errs
+}
+
+// IsSafeName проверяет, что имя можно использовать как часть имени файла
+// в Windows и Unix
+func IsSafeName(name string) bool {
+	if name == "" || strings.TrimSpace(name) != name || strings.HasSuffix(name, ".") || name == ".." {
+		return false
+	}
+
+	for _, r := range name {
+		if r < 32 || strings.ContainsRune(unsafeChars, r) {
+			return false
+		}
+	}
+
+	for _, reserved := range reservedNames {
+		if strings.EqualFold(name, reserved) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func checkReadable(path string) error {
+	info, err := os.Stat(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("file does not exist")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return fmt.Errorf("is a directory, expected a file")
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return fmt.Errorf("file is not readable: %v", err)
+	}
+
+	return file.Close()
+}
+
+// jsonPath превращает Setting.files[0].name в files[0].name
+func jsonPath(namespace string) string {
+	_, path, found := strings.Cut(namespace, ".")
+
+	if !found {
+		return namespace
+	}
+
+	return path
+}
+
+func message(err validator.FieldError) string {
+	switch err.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", err.Param())
+	case "gt":
+		if err.Kind() == reflect.Int64 {
+			return "must be a positive duration"
+		}
+
+		return fmt.Sprintf("must be greater than %s", err.Param())
+	case "cron":
+		return "must be a cron expression of five fields or a descriptor like @daily"
+	case "excluded_with":
+		return "must not be set together with interval"
+	case "required_with":
+		return fmt.Sprintf("is required when %s is set", strings.ToLower(err.Param()))
+	case "email":
+		return "must be an email address"
+	case "url":
+		return "must be a URL"
+	case "lte":
+		return fmt.Sprintf("must be at most %s", err.Param())
+	case "hostname_port":
+		return "must be an address like host:port or :port"
+	case "endswith":
+		return fmt.Sprintf("must end with %s", err.Param())
+	case "fsname":
+		return fmt.Sprintf("must be a filesystem-safe name without %s", unsafeChars)
+	default:
+		return fmt.Sprintf("failed on %s", err.Tag())
+	}
+}
@@ -0,0 +1,272 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"yd_backup/internal/models"
+	"yd_backup/internal/usecase"
+)
+
+var emailTemplate = template.Must(template.New("email").Parse(`<html><body>
+<h3>{{.Subject}}</h3>
+<p>Started: {{.Started}}, duration: {{.Duration}}</p>
+{{if .Jobs}}<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Job</th><th>Status</th><th>Size</th><th>Duration</th><th>Destinations</th></tr>
+{{range .Jobs}}<tr><td>{{.Job}}</td><td>{{.Status}}</td><td>{{.Size}}</td><td>{{.Duration}}</td><td>{{range .Destinations}}{{.}}<br>{{end}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Checks}}<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Destination</th><th>Name</th><th>Result</th><th>Duration</th></tr>
+{{range .Checks}}<tr><td>{{.Destination}}</td><td>{{.Name}}</td><td>{{.Result}}</td><td>{{.Duration}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Stale}}<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Job</th><th>Last success</th><th>Age</th><th>Max age</th></tr>
+{{range .Stale}}<tr><td>{{.Job}}</td><td>{{.LastSuccess}}</td><td>{{.Age}}</td><td>{{.MaxAge}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Tokens}}<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Account</th><th>Expires</th><th>Result</th></tr>
+{{range .Tokens}}<tr><td>{{.Account}}</td><td>{{.Expires}}</td><td>{{.Result}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Errors}}<h4>Errors</h4><ul>{{range .Errors}}<li>{{.}}</li>{{end}}</ul>{{end}}
+</body></html>
+`))
+
+// Mailer отправляет отчеты по SMTP с STARTTLS, неявным TLS или без
+// шифрования. Адрес сервера задается конфигурацией, поэтому отправку можно
+// проверить на локальном SMTP-сервере
+type Mailer struct {
+	config models.Smtp
+	retry  models.Retry
+}
+
+func NewMailer(config models.Smtp, retry models.Retry) *Mailer {
+	return &Mailer{config: config, retry: retry}
+}
+
+func (m *Mailer) Name() string {
+	return "email"
+}
+
+func (m *Mailer) Notify(report usecase.Report) error {
+	message, err := m.message(report)
+
+	if err != nil {
+		return err
+	}
+
+	// Как и HTTP-каналы, повторяем сетевые ошибки и временные ответы 4xx
+	for attempt := 1; ; attempt++ {
+		err = m.send(message)
+
+		var retry *retryAfter
+
+		if err == nil || !errors.As(err, &retry) || attempt >= m.retry.Attempts {
+			return err
+		}
+
+		time.Sleep(m.retry.Delay.Duration * time.Duration(attempt))
+	}
+}
+
+// smtpError описывает ошибку этапа отправки. Сетевые ошибки, обрыв
+// соединения и временные ответы сервера 4xx можно повторить
+func smtpError(stage string, err error) error {
+	wrapped := fmt.Errorf("%s: %v", stage, err)
+
+	var protoError *textproto.Error
+	var netError net.Error
+
+	temporary := errors.As(err, &protoError) && protoError.Code >= 400 && protoError.Code < 500
+
+	if temporary || errors.As(err, &netError) || errors.Is(err, io.EOF) {
+		return &retryAfter{err: wrapped}
+	}
+
+	return wrapped
+}
+
+func (m *Mailer) send(message []byte) error {
+	address := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	dialer := &net.Dialer{Timeout: m.config.Timeout.Duration}
+
+	tlsConfig := &tls.Config{
+		ServerName:         m.config.Host,
+		InsecureSkipVerify: m.config.SkipVerify,
+	}
+
+	var conn net.Conn
+	var err error
+
+	if m.config.Security == models.SmtpTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+
+	if err != nil {
+		return smtpError("unable to connect to "+address, err)
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(m.config.Timeout.Duration)); err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, m.config.Host)
+
+	if err != nil {
+		conn.Close()
+		return smtpError("unable to start smtp session", err)
+	}
+
+	defer client.Close()
+
+	if hostname, err := os.Hostname(); err == nil {
+		if err := client.Hello(hostname); err != nil {
+			return smtpError("smtp hello failed", err)
+		}
+	}
+
+	if m.config.Security == models.SmtpStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server %s does not support STARTTLS", address)
+		}
+
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return smtpError("starttls failed", err)
+		}
+	}
+
+	if m.config.Username != "" {
+		auth := smtp.PlainAuth("", m.config.Username, m.config.Password.Value(), m.config.Host)
+
+		if err := client.Auth(auth); err != nil {
+			return smtpError("smtp auth failed", err)
+		}
+	}
+
+	if err := client.Mail(m.config.From); err != nil {
+		return smtpError("smtp MAIL FROM failed", err)
+	}
+
+	for _, to := range m.config.To {
+		if err := client.Rcpt(to); err != nil {
+			return smtpError("smtp RCPT TO "+to+" failed", err)
+		}
+	}
+
+	writer, err := client.Data()
+
+	if err != nil {
+		return smtpError("smtp DATA failed", err)
+	}
+
+	if _, err := writer.Write(message); err != nil {
+		return smtpError("unable to send message", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return smtpError("unable to send message", err)
+	}
+
+	return client.Quit()
+}
+
+// message собирает письмо multipart/alternative с текстовой и HTML-версией
+func (m *Mailer) message(report usecase.Report) ([]byte, error) {
+	var body bytes.Buffer
+
+	parts := multipart.NewWriter(&body)
+
+	var html bytes.Buffer
+
+	var checks []map[string]string
+
+	for _, check := range report.Checks {
+		checks = append(checks, map[string]string{
+			"Destination": check.Destination,
+			"Name":        check.Name,
+			"Result":      check.Result,
+			"Duration":    check.Duration.Round(time.Millisecond).String(),
+		})
+	}
+
+	err := emailTemplate.Execute(&html, map[string]interface{}{
+		"Subject":  Subject(report),
+		"Started":  report.Started.Local().Format(time.DateTime),
+		"Duration": report.Finished.Sub(report.Started).Round(time.Second).String(),
+		"Jobs":     JobRows(report),
+		"Checks":   checks,
+		"Stale":    StaleRows(report),
+		"Tokens":   TokenRows(report),
+		"Errors":   Errors(report),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", Text(report)},
+		{"text/html; charset=utf-8", html.String()},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := quotedprintable.NewWriter(writer)
+
+		if _, err := encoder.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+
+	headers := []string{
+		"From: " + m.config.From,
+		"To: " + strings.Join(m.config.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", Subject(report)),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + parts.Boundary(),
+	}
+
+	for _, header := range headers {
+		message.WriteString(header + "\r\n")
+	}
+
+	message.WriteString("\r\n")
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
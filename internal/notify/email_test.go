@@ -0,0 +1,409 @@
+package notify
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"yd_backup/internal/models"
+	"yd_backup/internal/usecase"
+)
+
+// smtpSession - то, что stub-сервер получил за одну сессию
+type smtpSession struct {
+	tls   bool
+	auth  string
+	from  string
+	rcpts []string
+	data  string
+}
+
+// smtpStub - SMTP-сервер в процессе теста. failMail первых сессий отвечает
+// на MAIL FROM временной ошибкой 421
+type smtpStub struct {
+	listener net.Listener
+	tls      *tls.Config
+	startTLS bool
+	password string
+	failMail int
+
+	mu       sync.Mutex
+	sessions []*smtpSession
+}
+
+// listen запускает сервер с уже заданными настройками stub
+func (s *smtpStub) listen(t *testing.T, implicitTLS bool) {
+	t.Helper()
+
+	s.tls = testTLSConfig(t)
+
+	var err error
+
+	if implicitTLS {
+		s.listener, err = tls.Listen("tcp", "127.0.0.1:0", s.tls)
+	} else {
+		s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+
+	t.Cleanup(func() { s.listener.Close() })
+
+	go s.serve()
+}
+
+func (s *smtpStub) config(security string) models.Smtp {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	return models.Smtp{
+		Host:       "127.0.0.1",
+		Port:       portNumber,
+		Security:   security,
+		From:       "backup@example.com",
+		To:         []string{"admin@example.com", "it@example.com"},
+		Timeout:    models.Duration{Duration: 5 * time.Second},
+		SkipVerify: true,
+	}
+}
+
+func (s *smtpStub) recorded() []smtpSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []smtpSession
+
+	for _, session := range s.sessions {
+		result = append(result, *session)
+	}
+
+	return result
+}
+
+func (s *smtpStub) serve() {
+	for {
+		conn, err := s.listener.Accept()
+
+		if err != nil {
+			return
+		}
+
+		session := &smtpSession{}
+
+		_, session.tls = conn.(*tls.Conn)
+
+		s.mu.Lock()
+		s.sessions = append(s.sessions, session)
+		failMail := len(s.sessions) <= s.failMail
+		s.mu.Unlock()
+
+		go s.handle(conn, session, failMail)
+	}
+}
+
+func (s *smtpStub) handle(conn net.Conn, session *smtpSession, failMail bool) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	reply := func(lines ...string) {
+		conn.Write([]byte(strings.Join(lines, "\r\n") + "\r\n"))
+	}
+
+	reply("220 stub ESMTP")
+
+	for {
+		line, err := reader.ReadString('\n')
+
+		if err != nil {
+			return
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch command {
+		case "EHLO":
+			extensions := []string{"250-stub"}
+
+			if s.startTLS && !session.tls {
+				extensions = append(extensions, "250-STARTTLS")
+			}
+
+			reply(append(extensions, "250 AUTH PLAIN")...)
+		case "STARTTLS":
+			reply("220 ready to start TLS")
+
+			tlsConn := tls.Server(conn, s.tls)
+
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+
+			conn, reader = tlsConn, bufio.NewReader(tlsConn)
+
+			s.mu.Lock()
+			session.tls = true
+			s.mu.Unlock()
+		case "AUTH":
+			parts := strings.Fields(line)
+			decoded, _ := base64.StdEncoding.DecodeString(parts[len(parts)-1])
+
+			s.mu.Lock()
+			session.auth = string(decoded)
+			s.mu.Unlock()
+
+			if strings.HasSuffix(string(decoded), "\x00"+s.password) {
+				reply("235 authenticated")
+			} else {
+				reply("535 authentication failed")
+			}
+		case "MAIL":
+			if failMail {
+				reply("421 try again later")
+				return
+			}
+
+			s.mu.Lock()
+			session.from = line
+			s.mu.Unlock()
+
+			reply("250 ok")
+		case "RCPT":
+			s.mu.Lock()
+			session.rcpts = append(session.rcpts, line)
+			s.mu.Unlock()
+
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+
+			var data strings.Builder
+
+			for {
+				dataLine, err := reader.ReadString('\n')
+
+				if err != nil {
+					return
+				}
+
+				if dataLine == ".\r\n" {
+					break
+				}
+
+				data.WriteString(dataLine)
+			}
+
+			s.mu.Lock()
+			session.data = data.String()
+			s.mu.Unlock()
+
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+// testTLSConfig - самоподписанный сертификат для 127.0.0.1
+func testTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatalf("unable to create certificate: %v", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{cert}, PrivateKey: key}},
+	}
+}
+
+func testReport() usecase.Report {
+	started := time.Now().Add(-time.Minute)
+
+	return usecase.Report{
+		Kind:      usecase.ReportBackup,
+		Host:      "host",
+		Status:    usecase.StatusFailed,
+		Started:   started,
+		Finished:  time.Now(),
+		Jobs:      []usecase.JobOutcome{{Job: "Trade", Started: started, Err: errors.New("disk full")}},
+		Triggered: []string{"Trade"},
+	}
+}
+
+func testRetry() models.Retry {
+	return models.Retry{Attempts: 3, Delay: models.Duration{Duration: 10 * time.Millisecond}}
+}
+
+func TestMailerStartTLS(t *testing.T) {
+	stub := &smtpStub{startTLS: true, password: "secret"}
+	stub.listen(t, false)
+
+	config := stub.config(models.SmtpStartTLS)
+	config.Username = "backup@example.com"
+	config.Password = models.NewSecret("secret")
+
+	if err := NewMailer(config, testRetry()).Notify(testReport()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	sessions := stub.recorded()
+
+	if len(sessions) != 1 {
+		t.Fatalf("sessions = %d, want 1", len(sessions))
+	}
+
+	session := sessions[0]
+
+	if !session.tls {
+		t.Errorf("session is not upgraded with STARTTLS")
+	}
+
+	if session.auth != "\x00backup@example.com\x00secret" {
+		t.Errorf("auth = %q, want PLAIN credentials", session.auth)
+	}
+
+	if session.from != "MAIL FROM:<backup@example.com>" {
+		t.Errorf("from = %q", session.from)
+	}
+
+	if len(session.rcpts) != 2 || !strings.Contains(session.rcpts[1], "it@example.com") {
+		t.Errorf("rcpts = %v, want both recipients", session.rcpts)
+	}
+
+	for _, want := range []string{"To: admin@example.com, it@example.com", "multipart/alternative", "Trade", "disk full"} {
+		if !strings.Contains(session.data, want) {
+			t.Errorf("message does not contain %q", want)
+		}
+	}
+}
+
+func TestMailerImplicitTLS(t *testing.T) {
+	stub := &smtpStub{}
+	stub.listen(t, true)
+
+	if err := NewMailer(stub.config(models.SmtpTLS), testRetry()).Notify(testReport()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if sessions := stub.recorded(); len(sessions) != 1 || !sessions[0].tls || sessions[0].data == "" {
+		t.Errorf("sessions = %+v, want one delivered TLS session", sessions)
+	}
+}
+
+func TestMailerPlain(t *testing.T) {
+	stub := &smtpStub{startTLS: true}
+	stub.listen(t, false)
+
+	if err := NewMailer(stub.config(models.SmtpNone), testRetry()).Notify(testReport()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	sessions := stub.recorded()
+
+	if len(sessions) != 1 {
+		t.Fatalf("sessions = %d, want 1", len(sessions))
+	}
+
+	// Без security STARTTLS не используется, а без username нет и AUTH
+	if sessions[0].tls || sessions[0].auth != "" || sessions[0].data == "" {
+		t.Errorf("session = %+v, want plain delivery without auth", sessions[0])
+	}
+}
+
+func TestMailerStartTLSUnsupported(t *testing.T) {
+	stub := &smtpStub{}
+	stub.listen(t, false)
+
+	err := NewMailer(stub.config(models.SmtpStartTLS), testRetry()).Notify(testReport())
+
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("err = %v, want STARTTLS error", err)
+	}
+}
+
+func TestMailerAuthFailed(t *testing.T) {
+	stub := &smtpStub{startTLS: true, password: "secret"}
+	stub.listen(t, false)
+
+	config := stub.config(models.SmtpStartTLS)
+	config.Username = "backup@example.com"
+	config.Password = models.NewSecret("wrong")
+
+	err := NewMailer(config, testRetry()).Notify(testReport())
+
+	if err == nil || !strings.Contains(err.Error(), "auth") {
+		t.Fatalf("err = %v, want auth error", err)
+	}
+
+	// Отказ в авторизации постоянный и не повторяется
+	if sessions := stub.recorded(); len(sessions) != 1 {
+		t.Errorf("sessions = %d, want 1", len(sessions))
+	}
+}
+
+func TestMailerRetry(t *testing.T) {
+	stub := &smtpStub{failMail: 2}
+	stub.listen(t, false)
+
+	if err := NewMailer(stub.config(models.SmtpNone), testRetry()).Notify(testReport()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	sessions := stub.recorded()
+
+	if len(sessions) != 3 || sessions[2].data == "" {
+		t.Fatalf("sessions = %d, want delivery on the third attempt", len(sessions))
+	}
+}
+
+func TestMailerRetryExhausted(t *testing.T) {
+	stub := &smtpStub{failMail: 5}
+	stub.listen(t, false)
+
+	err := NewMailer(stub.config(models.SmtpNone), testRetry()).Notify(testReport())
+
+	if err == nil || !strings.Contains(err.Error(), "421") {
+		t.Fatalf("err = %v, want 421 error", err)
+	}
+
+	if sessions := stub.recorded(); len(sessions) != 3 {
+		t.Errorf("sessions = %d, want 3 attempts", len(sessions))
+	}
+}
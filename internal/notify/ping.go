@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"fmt"
+	"github.com/valyala/fasthttp"
+	"net/url"
+	"strings"
+	"time"
+	"yd_backup/internal/models"
+	"yd_backup/internal/usecase"
+)
+
+// Ограничение тела сигнала; healthchecks.io хранит до 100 КБ
+const pingLimit = 10000
+
+// Healthchecks отправляет сигналы заданий в стиле healthchecks.io: start на
+// <url>/start, success на <url>, fail на <url>/fail. Идентификатор запуска
+// передается параметром rid, тело содержит журнал запуска
+type Healthchecks struct {
+	sender *sender
+}
+
+func NewHealthchecks(timeout time.Duration, retry models.Retry) *Healthchecks {
+	// Сигналы разных заданий не ждут друг друга
+	retry.Interval.Duration = 0
+
+	return &Healthchecks{sender: newSender(timeout, retry)}
+}
+
+func (h *Healthchecks) Ping(address string, event string, runID string, body string) error {
+	parsed, err := url.Parse(address)
+
+	if err != nil {
+		return fmt.Errorf("invalid ping url: %v", err)
+	}
+
+	switch event {
+	case usecase.PingStart:
+		parsed.Path = strings.TrimSuffix(parsed.Path, "/") + "/start"
+	case usecase.PingFail:
+		parsed.Path = strings.TrimSuffix(parsed.Path, "/") + "/fail"
+	}
+
+	query := parsed.Query()
+	query.Set("rid", runID)
+	parsed.RawQuery = query.Encode()
+
+	// Начало журнала менее полезно, чем ошибки в его конце
+	if len(body) > pingLimit {
+		body = "…" + strings.ToValidUTF8(body[len(body)-pingLimit:], "")
+	}
+
+	headers := map[string]string{fasthttp.HeaderContentType: "text/plain; charset=utf-8"}
+
+	return h.sender.post(parsed.String(), []byte(body), headers,
+		func(status int, body []byte, header *fasthttp.ResponseHeader) error {
+			if status >= 200 && status < 300 {
+				return nil
+			}
+
+			return statusError(status, body, header)
+		})
+}
@@ -0,0 +1,225 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"yd_backup/internal/usecase"
+)
+
+// Subject - тема уведомления: вид запуска, итог и хост
+func Subject(report usecase.Report) string {
+	subject := fmt.Sprintf("yd_backup: %s %s on %s", report.Kind, report.Status, report.Host)
+
+	if report.Kind == usecase.ReportBackup {
+		failed := 0
+
+		for i := range report.Jobs {
+			if report.Jobs[i].Status() != usecase.StatusSuccess {
+				failed++
+			}
+		}
+
+		subject += fmt.Sprintf(" (%d/%d jobs failed)", failed, len(report.Jobs))
+	}
+
+	if report.Kind == usecase.ReportStale {
+		subject += fmt.Sprintf(" (%d jobs)", len(report.Stale))
+	}
+
+	if report.Kind == usecase.ReportCredentials {
+		subject += fmt.Sprintf(" (%d accounts)", len(report.Tokens))
+	}
+
+	return subject
+}
+
+// Text - отчет в виде таблицы с моноширинными колонками и списком ошибок
+func Text(report usecase.Report) string {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "%s\n", Subject(report))
+	fmt.Fprintf(&builder, "Started: %s, duration: %s\n\n", report.Started.Local().Format(time.DateTime),
+		report.Finished.Sub(report.Started).Round(time.Second))
+
+	writer := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+
+	switch report.Kind {
+	case usecase.ReportBackup:
+		fmt.Fprintln(writer, "JOB\tSTATUS\tSIZE\tDURATION\tDESTINATIONS")
+
+		for _, row := range JobRows(report) {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", row.Job, row.Status, row.Size, row.Duration,
+				strings.Join(row.Destinations, ", "))
+		}
+	case usecase.ReportStale:
+		fmt.Fprintln(writer, "JOB\tLAST SUCCESS\tAGE\tMAX AGE")
+
+		for _, row := range StaleRows(report) {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", row.Job, row.LastSuccess, row.Age, row.MaxAge)
+		}
+	case usecase.ReportCredentials:
+		fmt.Fprintln(writer, "ACCOUNT\tEXPIRES\tRESULT")
+
+		for _, row := range TokenRows(report) {
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", row.Account, row.Expires, row.Result)
+		}
+	default:
+		fmt.Fprintln(writer, "DESTINATION\tNAME\tRESULT\tDURATION")
+
+		for _, check := range report.Checks {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", check.Destination, check.Name, check.Result,
+				check.Duration.Round(time.Millisecond))
+		}
+	}
+
+	writer.Flush()
+
+	if errors := Errors(report); len(errors) > 0 {
+		builder.WriteString("\nErrors:\n")
+
+		for _, err := range errors {
+			fmt.Fprintf(&builder, "- %s\n", err)
+		}
+	}
+
+	return builder.String()
+}
+
+// JobRow - строка таблицы заданий с уже отформатированными значениями
+type JobRow struct {
+	Job          string
+	Status       string
+	Size         string
+	Duration     string
+	Destinations []string
+}
+
+func JobRows(report usecase.Report) []JobRow {
+	var rows []JobRow
+
+	for i := range report.Jobs {
+		job := &report.Jobs[i]
+
+		row := JobRow{
+			Job:      job.Job,
+			Status:   job.Status().String(),
+			Size:     FormatSize(job.Bytes),
+			Duration: job.Duration().Round(time.Second).String(),
+		}
+
+		for _, destination := range job.Destinations {
+			result := "ok"
+
+			if destination.Err != nil {
+				result = "error"
+			}
+
+			row.Destinations = append(row.Destinations, destination.Name+": "+result)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// StaleRow - строка таблицы устаревших заданий
+type StaleRow struct {
+	Job         string
+	LastSuccess string
+	Age         string
+	MaxAge      string
+}
+
+func StaleRows(report usecase.Report) []StaleRow {
+	var rows []StaleRow
+
+	for _, job := range report.Stale {
+		row := StaleRow{Job: job.Job, LastSuccess: "never", Age: "-", MaxAge: job.MaxAge.String()}
+
+		if !job.LastSuccess.IsZero() {
+			row.LastSuccess = job.LastSuccess.Local().Format(time.DateTime)
+			row.Age = job.Age.Round(time.Minute).String()
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// TokenRow - строка таблицы токенов аккаунтов
+type TokenRow struct {
+	Account string
+	Expires string
+	Result  string
+}
+
+func TokenRows(report usecase.Report) []TokenRow {
+	var rows []TokenRow
+
+	for _, check := range report.Tokens {
+		rows = append(rows, TokenRow{
+			Account: check.Account,
+			Expires: check.ExpiresAt.Local().Format(time.DateTime),
+			Result:  check.Result,
+		})
+	}
+
+	return rows
+}
+
+// Errors - ошибки заданий, назначений, проверок и обновления токенов отчета
+func Errors(report usecase.Report) []string {
+	var result []string
+
+	for _, job := range report.Jobs {
+		for _, destination := range job.Destinations {
+			if destination.Err != nil {
+				result = append(result, fmt.Sprintf("%s: %s: %v", job.Job, destination.Name, destination.Err))
+			}
+		}
+
+		if job.Err != nil {
+			result = append(result, fmt.Sprintf("%s: %v", job.Job, job.Err))
+		}
+
+		for _, anomaly := range job.Anomalies {
+			result = append(result, fmt.Sprintf("%s: suspicious backup: %s", job.Job, anomaly))
+		}
+	}
+
+	for _, check := range report.Checks {
+		if check.Result != usecase.RestoreOk {
+			result = append(result, fmt.Sprintf("%s: %s: %s %s", check.Destination, check.Name, check.Result, check.Detail))
+		}
+	}
+
+	for _, check := range report.Tokens {
+		if check.Detail != "" {
+			result = append(result, fmt.Sprintf("%s: %s: %s", check.Account, check.Result, check.Detail))
+		}
+	}
+
+	return result
+}
+
+// FormatSize переводит байты в единицы с двоичным множителем
+func FormatSize(size int64) string {
+	const unit = 1024
+
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value, exp := float64(size)/unit, 0
+
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[exp])
+}
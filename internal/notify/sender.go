@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"github.com/valyala/fasthttp"
+	"strconv"
+	"sync"
+	"time"
+	"yd_backup/internal/models"
+)
+
+// retryAfter - ошибка, после которой отправку можно повторить. After -
+// пауза, которую попросил сервер, или ноль
+type retryAfter struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryAfter) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfter) Unwrap() error {
+	return e.err
+}
+
+// sender отправляет HTTP-запросы канала с ограничением частоты и повтором
+// при сетевых ошибках, ответах 429 и 5xx
+type sender struct {
+	client  *fasthttp.Client
+	timeout time.Duration
+	retry   models.Retry
+	mu      sync.Mutex
+	last    time.Time
+}
+
+func newSender(timeout time.Duration, retry models.Retry) *sender {
+	return &sender{
+		client: &fasthttp.Client{
+			ReadTimeout:  timeout,
+			WriteTimeout: timeout,
+		},
+		timeout: timeout,
+		retry:   retry,
+	}
+}
+
+// post отправляет body, повторяя запрос, пока check возвращает retryAfter
+// и не исчерпаны попытки. Пауза растет с каждой попыткой
+func (s *sender) post(url string, body []byte, headers map[string]string,
+	check func(status int, body []byte, header *fasthttp.ResponseHeader) error) error {
+
+	var err error
+
+	for attempt := 1; ; attempt++ {
+		s.wait()
+
+		err = s.do(url, body, headers, check)
+
+		var retry *retryAfter
+
+		if err == nil || !errors.As(err, &retry) || attempt >= s.retry.Attempts {
+			return err
+		}
+
+		delay := s.retry.Delay.Duration * time.Duration(attempt)
+
+		if retry.after > delay {
+			delay = retry.after
+		}
+
+		time.Sleep(delay)
+	}
+}
+
+func (s *sender) do(url string, body []byte, headers map[string]string,
+	check func(status int, body []byte, header *fasthttp.ResponseHeader) error) error {
+
+	request := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(request)
+	response := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(response)
+
+	request.SetRequestURI(url)
+	request.Header.SetMethod(fasthttp.MethodPost)
+	request.Header.SetContentType("application/json")
+
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	request.SetBody(body)
+
+	if err := s.client.DoTimeout(request, response, s.timeout); err != nil {
+		return &retryAfter{err: err}
+	}
+
+	return check(response.StatusCode(), response.Body(), &response.Header)
+}
+
+// wait выдерживает минимальный интервал между сообщениями канала
+func (s *sender) wait() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if delay := time.Until(s.last.Add(s.retry.Interval.Duration)); delay > 0 {
+		time.Sleep(delay)
+	}
+
+	s.last = time.Now()
+}
+
+// statusError - ошибка по коду ответа; 429 и 5xx можно повторить
+func statusError(status int, body []byte, header *fasthttp.ResponseHeader) error {
+	err := fmt.Errorf("unexpected status code %d: %s", status, truncate(string(body), 200))
+
+	if status == fasthttp.StatusTooManyRequests || status >= 500 {
+		after, _ := strconv.Atoi(string(header.Peek(fasthttp.HeaderRetryAfter)))
+
+		return &retryAfter{err: err, after: time.Duration(after) * time.Second}
+	}
+
+	return err
+}
+
+func truncate(value string, limit int) string {
+	runes := []rune(value)
+
+	if len(runes) <= limit {
+		return value
+	}
+
+	return string(runes[:limit-1]) + "…"
+}
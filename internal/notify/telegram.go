@@ -0,0 +1,138 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/valyala/fasthttp"
+	"html"
+	"strings"
+	"time"
+	"yd_backup/internal/models"
+	"yd_backup/internal/usecase"
+)
+
+// Ограничение длины сообщения Telegram
+const telegramLimit = 4096
+
+// Telegram отправляет отчет в чаты через Bot API. Адрес API задается
+// конфигурацией, поэтому вместо api.telegram.org можно подставить тестовый сервер
+type Telegram struct {
+	config models.Telegram
+	sender *sender
+}
+
+type telegramResponse struct {
+	Ok          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+func NewTelegram(config models.Telegram, retry models.Retry) *Telegram {
+	return &Telegram{
+		config: config,
+		sender: newSender(config.Timeout.Duration, retry),
+	}
+}
+
+func (t *Telegram) Name() string {
+	return "telegram"
+}
+
+// Notify отправляет отчет во все чаты. Ошибка одного чата не мешает остальным
+func (t *Telegram) Notify(report usecase.Report) error {
+	text := telegramText(report)
+
+	url := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimSuffix(t.config.URL, "/"), t.config.Token.Value())
+
+	var failed []string
+
+	for _, chatID := range t.config.ChatIDs {
+		body, err := json.Marshal(map[string]interface{}{
+			"chat_id":                  chatID,
+			"text":                     text,
+			"parse_mode":               "HTML",
+			"disable_web_page_preview": true,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if err := t.sender.post(url, body, nil, telegramCheck); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", chatID, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to send to chats %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+func telegramCheck(status int, body []byte, header *fasthttp.ResponseHeader) error {
+	var response telegramResponse
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return statusError(status, body, header)
+	}
+
+	if response.Ok {
+		return nil
+	}
+
+	err := fmt.Errorf("telegram error %d: %s", response.ErrorCode, response.Description)
+
+	if response.ErrorCode == fasthttp.StatusTooManyRequests || response.ErrorCode >= 500 {
+		return &retryAfter{err: err, after: time.Duration(response.Parameters.RetryAfter) * time.Second}
+	}
+
+	return err
+}
+
+// telegramText - тема жирным и таблица отчета моноширинным блоком. Текст
+// сначала экранируется и только потом обрезается, чтобы сообщение вместе с
+// тегами и сущностями HTML не превысило лимит Telegram
+func telegramText(report usecase.Report) string {
+	subject := "<b>" + html.EscapeString(Subject(report)) + "</b>\n"
+
+	text := html.EscapeString(Text(report))
+
+	if limit := telegramLimit - len([]rune(subject)) - len("<pre></pre>"); len([]rune(text)) > limit {
+		text = truncateEscaped(text, limit)
+	}
+
+	return subject + "<pre>" + text + "</pre>"
+}
+
+// truncateEscaped обрезает экранированный текст до limit символов вместе с
+// многоточием, не разрывая сущности вида &amp;
+func truncateEscaped(text string, limit int) string {
+	runes := []rune(text)
+
+	if len(runes) <= limit {
+		return text
+	}
+
+	runes = runes[:limit-1]
+
+	// Незакрытая сущность в конце отрезается целиком
+	if amp := lastIndex(runes, '&'); amp >= 0 && lastIndex(runes[amp:], ';') < 0 {
+		runes = runes[:amp]
+	}
+
+	return string(runes) + "…"
+}
+
+func lastIndex(runes []rune, value rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] == value {
+			return i
+		}
+	}
+
+	return -1
+}
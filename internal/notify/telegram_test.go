@@ -0,0 +1,187 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"yd_backup/internal/models"
+)
+
+// telegramStub - stand-in для Bot API. Ответы для чата берутся из очереди
+// statuses, после ее исчерпания отвечает ok
+type telegramStub struct {
+	mu       sync.Mutex
+	statuses map[string][]int
+	requests []map[string]interface{}
+	paths    []string
+}
+
+func (s *telegramStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	chatID, _ := body["chat_id"].(string)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, body)
+	s.paths = append(s.paths, r.URL.Path)
+
+	status := http.StatusOK
+
+	if queue := s.statuses[chatID]; len(queue) > 0 {
+		status, s.statuses[chatID] = queue[0], queue[1:]
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if status == http.StatusOK {
+		w.Write([]byte(`{"ok": true, "result": {}}`))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ok":          false,
+		"error_code":  status,
+		"description": http.StatusText(status),
+	})
+}
+
+func (s *telegramStub) chats() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []string
+
+	for _, request := range s.requests {
+		chatID, _ := request["chat_id"].(string)
+		result = append(result, chatID)
+	}
+
+	return result
+}
+
+func newTestTelegram(t *testing.T, stub *telegramStub, chatIDs ...string) *Telegram {
+	t.Helper()
+
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	return NewTelegram(models.Telegram{
+		Token:   models.NewSecret("123:abc"),
+		ChatIDs: chatIDs,
+		URL:     server.URL + "/",
+		Timeout: models.Duration{Duration: 5 * time.Second},
+	}, testRetry())
+}
+
+func TestTelegramNotify(t *testing.T) {
+	stub := &telegramStub{}
+
+	if err := newTestTelegram(t, stub, "-100", "200").Notify(testReport()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if chats := stub.chats(); strings.Join(chats, ",") != "-100,200" {
+		t.Fatalf("chats = %v, want message to every chat", chats)
+	}
+
+	for _, path := range stub.paths {
+		if path != "/bot123:abc/sendMessage" {
+			t.Errorf("path = %q, want sendMessage of the bot", path)
+		}
+	}
+
+	request := stub.requests[0]
+
+	if request["parse_mode"] != "HTML" || request["disable_web_page_preview"] != true {
+		t.Errorf("request = %v, want HTML without preview", request)
+	}
+
+	text, _ := request["text"].(string)
+
+	for _, want := range []string{"<b>yd_backup: backup failed on host", "<pre>", "Trade", "disk full"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text does not contain %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestTelegramRetry(t *testing.T) {
+	stub := &telegramStub{statuses: map[string][]int{"-100": {http.StatusInternalServerError, http.StatusBadGateway}}}
+
+	if err := newTestTelegram(t, stub, "-100").Notify(testReport()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if chats := stub.chats(); len(chats) != 3 {
+		t.Errorf("requests = %d, want delivery on the third attempt", len(chats))
+	}
+}
+
+func TestTelegramChatFailure(t *testing.T) {
+	stub := &telegramStub{statuses: map[string][]int{"-100": {http.StatusBadRequest}}}
+
+	err := newTestTelegram(t, stub, "-100", "200").Notify(testReport())
+
+	if err == nil || !strings.Contains(err.Error(), "-100") || strings.Contains(err.Error(), "200:") {
+		t.Fatalf("err = %v, want error of chat -100 only", err)
+	}
+
+	// Ошибка 400 не повторяется и не мешает отправке в остальные чаты
+	if chats := stub.chats(); strings.Join(chats, ",") != "-100,200" {
+		t.Errorf("chats = %v, want one attempt per chat", chats)
+	}
+}
+
+func TestTelegramTextLimit(t *testing.T) {
+	report := testReport()
+
+	// Каждый символ < после экранирования занимает четыре
+	report.Jobs[0].Err = errors.New(strings.Repeat("<", 3000))
+
+	text := telegramText(report)
+
+	if length := len([]rune(text)); length > telegramLimit {
+		t.Errorf("text length = %d, want at most %d", length, telegramLimit)
+	}
+
+	if !strings.HasSuffix(text, "…</pre>") {
+		t.Errorf("text does not end with truncated block: %q", text[len(text)-40:])
+	}
+
+	body := strings.TrimSuffix(text[strings.Index(text, "<pre>")+len("<pre>"):], "…</pre>")
+
+	if strings.ContainsAny(body, "<>") || strings.Count(body, "&") != strings.Count(body, "&lt;") {
+		t.Errorf("truncated text contains raw or broken entities: %q", body[len(body)-40:])
+	}
+}
+
+func TestTruncateEscaped(t *testing.T) {
+	cases := []struct {
+		text  string
+		limit int
+		want  string
+	}{
+		{"a&amp;b", 10, "a&amp;b"},
+		{"abc&amp;d", 6, "abc…"},
+		{"abc&amp;de", 9, "abc&amp;…"},
+		{"абв&lt;где", 5, "абв…"},
+	}
+
+	for _, c := range cases {
+		if got := truncateEscaped(c.text, c.limit); got != c.want {
+			t.Errorf("truncateEscaped(%q, %d) = %q, want %q", c.text, c.limit, got, c.want)
+		}
+	}
+}
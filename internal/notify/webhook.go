@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/valyala/fasthttp"
+	"text/template"
+	"yd_backup/internal/models"
+	"yd_backup/internal/usecase"
+)
+
+// Webhook отправляет отчет POST-запросом на произвольный адрес
+type Webhook struct {
+	config   models.Webhook
+	template *template.Template
+	sender   *sender
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+	"text":    Text,
+	"subject": Subject,
+	"size":    FormatSize,
+}
+
+// NewWebhook разбирает шаблон тела запроса. В шаблоне доступны поля
+// usecase.Report и функции json, text, subject и size
+func NewWebhook(config models.Webhook, retry models.Retry) (*Webhook, error) {
+	webhook := &Webhook{
+		config: config,
+		sender: newSender(config.Timeout.Duration, retry),
+	}
+
+	if config.Template != "" {
+		parsed, err := template.New(config.Name).Funcs(templateFuncs).Parse(config.Template)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid template of webhook %s: %v", config.Name, err)
+		}
+
+		webhook.template = parsed
+	}
+
+	return webhook, nil
+}
+
+func (w *Webhook) Name() string {
+	return "webhook " + w.config.Name
+}
+
+func (w *Webhook) Notify(report usecase.Report) error {
+	body, err := w.body(report)
+
+	if err != nil {
+		return err
+	}
+
+	headers := make(map[string]string, len(w.config.Headers)+1)
+
+	for name, value := range w.config.Headers {
+		headers[name] = value.Value()
+	}
+
+	if secret := w.config.Secret.Value(); secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+
+		headers[w.config.SignatureHeader] = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	return w.sender.post(w.config.URL, body, headers,
+		func(status int, body []byte, header *fasthttp.ResponseHeader) error {
+			if status >= 200 && status < 300 {
+				return nil
+			}
+
+			return statusError(status, body, header)
+		})
+}
+
+func (w *Webhook) body(report usecase.Report) ([]byte, error) {
+	if w.template == nil {
+		return json.Marshal(report)
+	}
+
+	var body bytes.Buffer
+
+	if err := w.template.Execute(&body, report); err != nil {
+		return nil, fmt.Errorf("unable to render webhook %s: %v", w.config.Name, err)
+	}
+
+	return body.Bytes(), nil
+}
@@ -0,0 +1,204 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"yd_backup/internal/models"
+)
+
+// webhookStub - получатель webhook. Отвечает кодами из statuses по очереди,
+// затем 204
+type webhookStub struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (s *webhookStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.bodies = append(s.bodies, body)
+	s.headers = append(s.headers, r.Header.Clone())
+
+	status := http.StatusNoContent
+
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	s.mu.Unlock()
+
+	w.WriteHeader(status)
+}
+
+func (s *webhookStub) requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.bodies)
+}
+
+func newTestWebhook(t *testing.T, stub *webhookStub, config models.Webhook) *Webhook {
+	t.Helper()
+
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	config.Name = "ops"
+	config.URL = server.URL + "/hook"
+	config.Timeout = models.Duration{Duration: 5 * time.Second}
+
+	if config.SignatureHeader == "" {
+		config.SignatureHeader = "X-Signature-256"
+	}
+
+	webhook, err := NewWebhook(config, testRetry())
+
+	if err != nil {
+		t.Fatalf("NewWebhook: %v", err)
+	}
+
+	return webhook
+}
+
+func TestWebhookJSON(t *testing.T) {
+	stub := &webhookStub{}
+
+	webhook := newTestWebhook(t, stub, models.Webhook{
+		Headers: map[string]models.Secret{"Authorization": models.NewSecret("Bearer abc")},
+		Secret:  models.NewSecret("s3cret"),
+	})
+
+	if err := webhook.Notify(testReport()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if stub.requests() != 1 {
+		t.Fatalf("requests = %d, want 1", stub.requests())
+	}
+
+	body, header := stub.bodies[0], stub.headers[0]
+
+	var report struct {
+		Kind      string   `json:"kind"`
+		Status    string   `json:"status"`
+		Host      string   `json:"host"`
+		Triggered []string `json:"triggered"`
+		Jobs      []struct {
+			Job string `json:"job"`
+		} `json:"jobs"`
+	}
+
+	if err := json.Unmarshal(body, &report); err != nil {
+		t.Fatalf("body is not JSON: %v\n%s", err, body)
+	}
+
+	if report.Kind != "backup" || report.Status != "failed" || report.Host != "host" ||
+		len(report.Jobs) != 1 || report.Jobs[0].Job != "Trade" || len(report.Triggered) != 1 {
+
+		t.Errorf("report = %+v, want failed backup report of Trade", report)
+	}
+
+	if header.Get("Content-Type") != "application/json" || header.Get("Authorization") != "Bearer abc" {
+		t.Errorf("headers = %v, want JSON with Authorization", header)
+	}
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+
+	if signature := header.Get("X-Signature-256"); signature != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("signature = %q does not match body", signature)
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	stub := &webhookStub{}
+
+	webhook := newTestWebhook(t, stub, models.Webhook{
+		Template: `{"text": {{json (subject .)}}, "failed": {{len .Triggered}}, "kind": "{{.Kind}}"}`,
+	})
+
+	if err := webhook.Notify(testReport()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	var body map[string]interface{}
+
+	if err := json.Unmarshal(stub.bodies[0], &body); err != nil {
+		t.Fatalf("body is not JSON: %v\n%s", err, stub.bodies[0])
+	}
+
+	if body["kind"] != "backup" || body["failed"] != float64(1) ||
+		!strings.HasPrefix(body["text"].(string), "yd_backup: backup failed on host") {
+
+		t.Errorf("body = %v, want rendered template", body)
+	}
+
+	// Без secret подпись не добавляется
+	if signature := stub.headers[0].Get("X-Signature-256"); signature != "" {
+		t.Errorf("signature = %q, want none", signature)
+	}
+}
+
+func TestWebhookInvalidTemplate(t *testing.T) {
+	_, err := NewWebhook(models.Webhook{Name: "ops", Template: "{{.Kind"}, testRetry())
+
+	if err == nil || !strings.Contains(err.Error(), "ops") {
+		t.Errorf("err = %v, want template error of webhook ops", err)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	stub := &webhookStub{statuses: []int{http.StatusServiceUnavailable, http.StatusInternalServerError}}
+
+	if err := newTestWebhook(t, stub, models.Webhook{}).Notify(testReport()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if stub.requests() != 3 {
+		t.Errorf("requests = %d, want delivery on the third attempt", stub.requests())
+	}
+
+	// Повтор отправляет то же тело
+	if string(stub.bodies[0]) != string(stub.bodies[2]) {
+		t.Errorf("retried body differs from the first one")
+	}
+}
+
+func TestWebhookRetryExhausted(t *testing.T) {
+	stub := &webhookStub{statuses: []int{500, 502, 503, 504}}
+
+	err := newTestWebhook(t, stub, models.Webhook{}).Notify(testReport())
+
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("err = %v, want status 503 of the last attempt", err)
+	}
+
+	if stub.requests() != 3 {
+		t.Errorf("requests = %d, want 3 attempts", stub.requests())
+	}
+}
+
+func TestWebhookClientError(t *testing.T) {
+	stub := &webhookStub{statuses: []int{http.StatusBadRequest}}
+
+	err := newTestWebhook(t, stub, models.Webhook{}).Notify(testReport())
+
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("err = %v, want status 400", err)
+	}
+
+	if stub.requests() != 1 {
+		t.Errorf("requests = %d, want no retry on 4xx", stub.requests())
+	}
+}
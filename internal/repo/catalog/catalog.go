@@ -0,0 +1,192 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"yd_backup/internal/models"
+	"yd_backup/internal/repo"
+	"yd_backup/internal/repo/lock"
+)
+
+// Catalog - каталог копий в JSON-файле. Как и журнал загрузок, файл
+// перечитывается перед каждой операцией
+type Catalog struct {
+	mu     sync.Mutex
+	path   string
+	locker *lock.Locker
+}
+
+func NewCatalog(path string) *Catalog {
+	return &Catalog{path: path}
+}
+
+// SetLocker включает блокировку файла каталога между процессами, например демоном
+// и ручным запуском с той же конфигурацией
+func (c *Catalog) SetLocker(locker *lock.Locker) {
+	c.locker = locker
+}
+
+// lockFile захватывает блокировку файла между процессами на время
+// чтения-изменения-записи. Без locker остается только мьютекс процесса
+func (c *Catalog) lockFile() (func(), error) {
+	if c.locker == nil {
+		return func() {}, nil
+	}
+
+	return c.locker.Wait("catalog", lock.FileTimeout)
+}
+
+// Put добавляет запись или заменяет запись с тем же ID
+func (c *Catalog) Put(entry models.CatalogEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	unlock, err := c.lockFile()
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	entries, err := c.read()
+
+	if err != nil {
+		return err
+	}
+
+	for i := range entries {
+		if entries[i].ID == entry.ID {
+			entries[i] = entry
+			return c.write(entries)
+		}
+	}
+
+	return c.write(append(entries, entry))
+}
+
+// Update изменяет запись с указанным ID. Отсутствие записи не считается ошибкой
+func (c *Catalog) Update(id string, update func(entry *models.CatalogEntry)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	unlock, err := c.lockFile()
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	entries, err := c.read()
+
+	if err != nil {
+		return err
+	}
+
+	for i := range entries {
+		if entries[i].ID == id {
+			update(&entries[i])
+			return c.write(entries)
+		}
+	}
+
+	return nil
+}
+
+// Replace заменяет все записи каталога
+func (c *Catalog) Replace(entries []models.CatalogEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	unlock, err := c.lockFile()
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	return c.write(entries)
+}
+
+// Entries возвращает записи каталога в порядке создания копий
+func (c *Catalog) Entries() ([]models.CatalogEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := c.read()
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Created.Before(entries[j].Created)
+	})
+
+	return entries, nil
+}
+
+// Data возвращает содержимое каталога для загрузки в назначения
+func (c *Catalog) Data() ([]byte, error) {
+	entries, err := c.Entries()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return marshal(entries)
+}
+
+func (c *Catalog) read() ([]models.CatalogEntry, error) {
+	var entries []models.CatalogEntry
+
+	data, err := os.ReadFile(c.path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read catalog %s: %v", c.path, err)
+	}
+
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("unable to parse catalog %s: %v", c.path, err)
+	}
+
+	return entries, nil
+}
+
+func (c *Catalog) write(entries []models.CatalogEntry) error {
+	data, err := marshal(entries)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create catalog dir: %v", err)
+	}
+
+	if err := repo.AtomicWrite(c.path, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("unable to write catalog %s: %v", c.path, err)
+	}
+
+	return nil
+}
+
+func marshal(entries []models.CatalogEntry) ([]byte, error) {
+	if entries == nil {
+		entries = []models.CatalogEntry{}
+	}
+
+	return json.MarshalIndent(entries, "", "  ")
+}
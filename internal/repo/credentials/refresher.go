@@ -0,0 +1,59 @@
+package credentials
+
+import (
+	"fmt"
+	"yd_backup/pkg/yandex/oauth"
+	"yd_backup/pkg/yandex/oauth/models"
+)
+
+// Refresher обновляет токен аккаунта через OAuth и сохраняет новую пару
+// токенов в Store. Реализует disk.TokenRefresher
+type Refresher struct {
+	store   *Store
+	account string
+	client  *oauth.Client
+}
+
+func NewRefresher(store *Store, account string, client *oauth.Client) *Refresher {
+	return &Refresher{
+		store:   store,
+		account: account,
+		client:  client,
+	}
+}
+
+// Refresh обновляет токен под блокировкой файла токенов. Если за это время
+// токен уже обновил другой процесс, возвращается его токен без нового
+// запроса к OAuth
+func (r *Refresher) Refresh() (string, error) {
+	rejected, _ := r.store.Get(r.account)
+
+	var accessToken string
+
+	err := r.store.Update(r.account, func(current models.Token, ok bool) (models.Token, error) {
+		if !ok {
+			return current, fmt.Errorf("no stored credentials for account %s, run login", r.account)
+		}
+
+		if current.AccessToken != rejected.AccessToken {
+			accessToken = current.AccessToken
+			return current, nil
+		}
+
+		token, err := r.client.Refresh(current.RefreshToken)
+
+		if err != nil {
+			return current, fmt.Errorf("unable to refresh token of account %s: %v", r.account, err)
+		}
+
+		if token.RefreshToken == "" {
+			token.RefreshToken = current.RefreshToken
+		}
+
+		accessToken = token.AccessToken
+
+		return token, nil
+	})
+
+	return accessToken, err
+}
@@ -0,0 +1,143 @@
+package credentials
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"yd_backup/internal/repo/lock"
+	"yd_backup/pkg/yandex/oauth"
+	"yd_backup/pkg/yandex/oauth/models"
+)
+
+// fakeOAuth выдает по каждому refresh-токену новую пару токенов с номером
+// запроса и отвечает invalid_grant на уже использованный refresh-токен
+func fakeOAuth(t *testing.T) (*oauth.Client, func() int) {
+	t.Helper()
+
+	var mu sync.Mutex
+
+	used := make(map[string]bool)
+	calls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+
+		refreshToken := r.PostForm.Get("refresh_token")
+
+		if used[refreshToken] {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant"}`))
+			return
+		}
+
+		used[refreshToken] = true
+		calls++
+
+		fmt.Fprintf(w, `{"access_token": "access%d", "refresh_token": "refresh%d", "expires_in": 3600}`, calls, calls)
+	}))
+	t.Cleanup(server.Close)
+
+	client := oauth.NewClient("client", "secret")
+	client.URL = server.URL
+
+	return client, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return calls
+	}
+}
+
+// newProcessStore - хранилище токенов отдельного процесса над общим файлом
+func newProcessStore(t *testing.T, path string) *Store {
+	t.Helper()
+
+	store := NewStore(path)
+	store.SetLocker(lock.NewLocker(filepath.Join(filepath.Dir(path), "locks"), "config.json"))
+
+	if err := store.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	return store
+}
+
+func TestRefreshSharedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+
+	initial := models.Token{AccessToken: "access0", RefreshToken: "refresh0", ExpiresAt: time.Now()}
+
+	if err := NewStore(path).Set("org1", initial); err != nil {
+		t.Fatal(err)
+	}
+
+	client, calls := fakeOAuth(t)
+
+	daemon := newProcessStore(t, path)
+	manual := newProcessStore(t, path)
+
+	token, err := NewRefresher(daemon, "org1", client).Refresh()
+
+	if err != nil || token != "access1" {
+		t.Fatalf("daemon Refresh = %q, %v, want access1", token, err)
+	}
+
+	// Второй процесс еще держит в памяти отклоненный токен. Повторное
+	// обновление по уже отозванному refresh0 закончилось бы invalid_grant
+	token, err = NewRefresher(manual, "org1", client).Refresh()
+
+	if err != nil || token != "access1" {
+		t.Fatalf("manual Refresh = %q, %v, want token refreshed by daemon", token, err)
+	}
+
+	if calls() != 1 {
+		t.Errorf("OAuth calls = %d, want 1", calls())
+	}
+
+	stored, _ := newProcessStore(t, path).Get("org1")
+
+	if stored.AccessToken != "access1" || stored.RefreshToken != "refresh1" {
+		t.Errorf("stored = %+v, want refreshed pair", stored)
+	}
+}
+
+func TestRefreshConcurrentProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+
+	if err := NewStore(path).Set("org1", models.Token{AccessToken: "access0", RefreshToken: "refresh0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	client, calls := fakeOAuth(t)
+
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 4; i++ {
+		store := newProcessStore(t, path)
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if token, err := NewRefresher(store, "org1", client).Refresh(); err != nil || token != "access1" {
+				t.Errorf("Refresh = %q, %v, want access1", token, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if calls() != 1 {
+		t.Errorf("OAuth calls = %d, want one refresh for all processes", calls())
+	}
+}
@@ -0,0 +1,157 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"yd_backup/internal/repo"
+	"yd_backup/internal/repo/lock"
+	"yd_backup/pkg/yandex/oauth/models"
+)
+
+// Store - файл с токенами OAuth по именам аккаунтов. Хранится отдельно от
+// config.json, чтобы конфигурацию можно было показывать и копировать
+type Store struct {
+	mu     sync.Mutex
+	path   string
+	locker *lock.Locker
+	tokens map[string]models.Token
+}
+
+func NewStore(path string) *Store {
+	return &Store{
+		path:   path,
+		tokens: make(map[string]models.Token),
+	}
+}
+
+// SetLocker включает блокировку файла токенов между процессами: демон и
+// ручной запуск не должны обновлять токен одновременно, так как обновление
+// отзывает прежний refresh-токен
+func (s *Store) SetLocker(locker *lock.Locker) {
+	s.locker = locker
+}
+
+// lockFile захватывает блокировку файла между процессами на время
+// чтения-изменения-записи. Без locker остается только мьютекс процесса
+func (s *Store) lockFile() (func(), error) {
+	if s.locker == nil {
+		return func() {}, nil
+	}
+
+	return s.locker.Wait("credentials", lock.FileTimeout)
+}
+
+// Load читает файл с токенами. Отсутствие файла не считается ошибкой
+func (s *Store) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.read()
+
+	if err != nil {
+		return err
+	}
+
+	s.tokens = tokens
+
+	return nil
+}
+
+func (s *Store) Get(account string) (models.Token, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[account]
+
+	return token, ok
+}
+
+// Set сохраняет токен аккаунта и сразу записывает файл
+func (s *Store) Set(account string, token models.Token) error {
+	return s.Update(account, func(models.Token, bool) (models.Token, error) {
+		return token, nil
+	})
+}
+
+// Update заменяет токен аккаунта результатом update. Файл перечитывается под
+// блокировкой, и update получает токен, записанный последним, в том числе
+// другим процессом. При ошибке update файл не меняется
+func (s *Store) Update(account string, update func(current models.Token, ok bool) (models.Token, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lockFile()
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	tokens, err := s.read()
+
+	if err != nil {
+		return err
+	}
+
+	s.tokens = tokens
+
+	current, ok := tokens[account]
+
+	token, err := update(current, ok)
+
+	if err != nil {
+		return err
+	}
+
+	s.tokens[account] = token
+
+	return s.save()
+}
+
+func (s *Store) read() (map[string]models.Token, error) {
+	tokens := make(map[string]models.Token)
+
+	data, err := os.ReadFile(s.path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return tokens, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credentials file %s: %v", s.path, err)
+	}
+
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("unable to parse credentials file %s: %v", s.path, err)
+	}
+
+	if tokens == nil {
+		tokens = make(map[string]models.Token)
+	}
+
+	return tokens, nil
+}
+
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.tokens, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create credentials dir: %v", err)
+	}
+
+	if err := repo.AtomicWrite(s.path, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("unable to write credentials file %s: %v", s.path, err)
+	}
+
+	return nil
+}
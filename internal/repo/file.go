@@ -0,0 +1,81 @@
+package repo
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const TmpSuffix = ".tmp"
+
+// AtomicWrite записывает данные во временный файл рядом с targetPath,
+// сбрасывает его на диск и атомарно переименовывает в итоговое имя,
+// чтобы в директории никогда не оказалось недописанного файла
+func AtomicWrite(targetPath string, reader io.Reader) error {
+	dir := filepath.Dir(targetPath)
+
+	tmpFile, err := os.CreateTemp(dir, "."+filepath.Base(targetPath)+".*"+TmpSuffix)
+
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file in %s: %v", dir, err)
+	}
+
+	tmpPath := tmpFile.Name()
+
+	if _, err = io.Copy(tmpFile, reader); err == nil {
+		err = tmpFile.Sync()
+	}
+
+	if errClose := tmpFile.Close(); err == nil {
+		err = errClose
+	}
+
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("unable to write file %s: %v", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, targetPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("unable to rename %s to %s: %v", tmpPath, targetPath, err)
+	}
+
+	syncDir(dir)
+
+	return nil
+}
+
+// AtomicCopy копирует файл через AtomicWrite с выводом прогресса
+func AtomicCopy(sourcePath string, targetPath string) error {
+	file, err := os.Open(sourcePath)
+
+	if err != nil {
+		return fmt.Errorf("unable to open file %s", sourcePath)
+	}
+
+	defer file.Close()
+
+	piper, err := NewWithFile(file)
+
+	if err != nil {
+		return err
+	}
+
+	return AtomicWrite(targetPath, piper)
+}
+
+// syncDir фиксирует переименование на диске. На части платформ (Windows,
+// некоторые сетевые ФС) директорию нельзя синхронизировать, поэтому ошибка
+// игнорируется: сам файл к этому моменту уже сброшен на диск
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+
+	if err != nil {
+		return
+	}
+
+	defer d.Close()
+
+	_ = d.Sync()
+}
@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"path"
+	"sync"
+)
+
+// UploadFolders - подпапки назначения, в которые сейчас идут загрузки.
+// Очистка не удаляет их, даже если они еще пусты: загрузка уже создала папку
+// или считает ее созданной. Пути относительны папке назначения, через /
+type UploadFolders struct {
+	mu     sync.Mutex
+	active map[string]int
+}
+
+// Enter отмечает папки пути name занятыми до вызова возвращенной функции.
+// Если в этот момент папка удаляется, Enter ждет окончания удаления
+func (f *UploadFolders) Enter(name string) func() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.active == nil {
+		f.active = make(map[string]int)
+	}
+
+	var folders []string
+
+	for folder := path.Dir(name); folder != "." && folder != "/"; folder = path.Dir(folder) {
+		folders = append(folders, folder)
+		f.active[folder]++
+	}
+
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		for _, folder := range folders {
+			if f.active[folder]--; f.active[folder] == 0 {
+				delete(f.active, folder)
+			}
+		}
+	}
+}
+
+// Remove вызывает remove для папки folder, только если в нее не идет
+// загрузка. Новые загрузки ждут окончания remove
+func (f *UploadFolders) Remove(folder string, remove func() error) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.active[folder] > 0 {
+		return false, nil
+	}
+
+	return true, remove()
+}
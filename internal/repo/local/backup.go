@@ -5,18 +5,26 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 	entity "yd_backup/internal/models"
 )
 
 type BackupLocal struct {
-	setting entity.Setting
+	setting   entity.Setting
+	protected func(path string) bool
 }
 
 func NewBackupLocal(setting entity.Setting) *BackupLocal {
 	return &BackupLocal{setting: setting}
 }
 
+// SetProtected задает проверку копий, которые нельзя удалять при очистке,
+// например копий с неподтвержденной загрузкой
+func (b *BackupLocal) SetProtected(protected func(path string) bool) {
+	b.protected = protected
+}
+
 func (b *BackupLocal) CreateBackup(path entity.Files) (string, error) {
 
 	file, err := os.OpenFile(path.Path, os.O_RDONLY, 0666)
@@ -52,7 +60,7 @@ func (b *BackupLocal) CreateBackup(path entity.Files) (string, error) {
 	_, err = io.Copy(backupFile, file)
 
 	if err != nil {
-		return "", fmt.Errorf("unable to copy source file %s to backup file %s", path, backupFilePath)
+		return "", fmt.Errorf("unable to copy source file %s to backup file %s", path.Path, backupFilePath)
 	}
 
 	return backupFile.Name(), nil
@@ -84,8 +92,14 @@ func (b *BackupLocal) EraseBackup() ([]string, error) {
 			return nil, fmt.Errorf("unable to get file info %s", file.Name())
 		}
 
+		path := filepath.Join(b.setting.Backup.Dir, file.Name())
+
+		if b.protected != nil && b.protected(path) {
+			continue
+		}
+
 		if fileInfo.ModTime().Add(b.setting.Backup.Expired.Duration).Before(time.Now()) {
-			err = os.Remove(filepath.Join(b.setting.Backup.Dir, file.Name()))
+			err = os.Remove(path)
 			if err != nil {
 				return nil, fmt.Errorf("unable to remove file %s", file.Name())
 			}
@@ -95,3 +109,38 @@ func (b *BackupLocal) EraseBackup() ([]string, error) {
 
 	return deletedFiles, nil
 }
+
+func (b *BackupLocal) ListBackup() ([]entity.BackupFile, error) {
+	var result []entity.BackupFile
+
+	files, err := os.ReadDir(b.setting.Backup.Dir)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read backup directory %s", b.setting.Backup.Dir)
+	}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		fileInfo, err := file.Info()
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to get file info %s", file.Name())
+		}
+
+		result = append(result, entity.BackupFile{
+			Name:    file.Name(),
+			Path:    filepath.Join(b.setting.Backup.Dir, file.Name()),
+			Size:    fileInfo.Size(),
+			Created: fileInfo.ModTime(),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Created.Before(result[j].Created)
+	})
+
+	return result, nil
+}
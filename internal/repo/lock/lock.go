@@ -0,0 +1,151 @@
+package lock
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileTimeout - время ожидания блокировки файлов каталога, журнала и состояния
+const FileTimeout = 30 * time.Second
+
+const waitInterval = 50 * time.Millisecond
+
+// errLocked - файл заблокирован другим процессом или другим Lock этого процесса
+var errLocked = errors.New("file is locked")
+
+// Owner - содержимое файла блокировки, только для сообщений об ошибке
+type Owner struct {
+	Pid     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Started time.Time `json:"started"`
+}
+
+// Locker - блокировки файлов средствами ОС (flock, LockFileEx). Блокировку
+// снимает система при завершении процесса-владельца, поэтому брошенных
+// блокировок не бывает. Имена файлов начинаются с хеша пути конфигурации,
+// поэтому несколько конфигураций могут делить одну директорию
+type Locker struct {
+	dir    string
+	prefix string
+}
+
+func NewLocker(dir string, configPath string) *Locker {
+	if absPath, err := filepath.Abs(configPath); err == nil {
+		configPath = absPath
+	}
+
+	hash := sha1.Sum([]byte(configPath))
+
+	return &Locker{
+		dir:    dir,
+		prefix: hex.EncodeToString(hash[:4]),
+	}
+}
+
+// Lock захватывает блокировку name и возвращает функцию ее снятия. Файлы
+// блокировок не удаляются: удаление между открытием и захватом файла другим
+// процессом дало бы две блокировки на разных файлах с одним именем
+func (l *Locker) Lock(name string) (func(), error) {
+	if err := os.MkdirAll(l.dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("unable to create lock dir %s: %v", l.dir, err)
+	}
+
+	path := filepath.Join(l.dir, fmt.Sprintf("%s-%s.lock", l.prefix, name))
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to open lock %s: %v", path, err)
+	}
+
+	if err := lockFile(file); err != nil {
+		file.Close()
+
+		if !errors.Is(err, errLocked) {
+			return nil, fmt.Errorf("unable to lock %s: %v", path, err)
+		}
+
+		current, err := read(path)
+
+		// Владелец мог еще не записать себя в файл или уже стереть
+		if err != nil || current.Pid == 0 {
+			return nil, fmt.Errorf("%s is locked by another process", name)
+		}
+
+		return nil, fmt.Errorf("%s is locked by pid %d on %s since %s", name, current.Pid, current.Host,
+			current.Started.Local().Format(time.DateTime))
+	}
+
+	host, _ := os.Hostname()
+
+	owner := Owner{
+		Pid:     os.Getpid(),
+		Host:    host,
+		Started: time.Now(),
+	}
+
+	if err := write(file, owner); err != nil {
+		unlockFile(file)
+		file.Close()
+
+		return nil, fmt.Errorf("unable to write lock %s: %v", path, err)
+	}
+
+	return func() {
+		file.Truncate(0)
+		unlockFile(file)
+		file.Close()
+	}, nil
+}
+
+// Wait захватывает блокировку name, ожидая ее освобождения не дольше timeout.
+// Используется для коротких операций чтения-изменения-записи общих файлов
+func (l *Locker) Wait(name string, timeout time.Duration) (func(), error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		unlock, err := l.Lock(name)
+
+		if err == nil || time.Now().After(deadline) {
+			return unlock, err
+		}
+
+		time.Sleep(waitInterval)
+	}
+}
+
+func write(file *os.File, owner Owner) error {
+	data, err := json.Marshal(owner)
+
+	if err != nil {
+		return err
+	}
+
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+
+	_, err = file.WriteAt(append(data, '\n'), 0)
+
+	return err
+}
+
+func read(path string) (Owner, error) {
+	var owner Owner
+
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return owner, err
+	}
+
+	err = json.Unmarshal(data, &owner)
+
+	return owner, err
+}
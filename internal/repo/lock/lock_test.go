@@ -0,0 +1,101 @@
+package lock
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHoldAndExit - вспомогательный процесс: захватывает блокировку и
+// завершается, не снимая ее
+func TestHoldAndExit(t *testing.T) {
+	dir := os.Getenv("LOCK_TEST_DIR")
+
+	if dir == "" {
+		t.Skip("helper process")
+	}
+
+	if _, err := NewLocker(dir, "config.json").Lock("job-Trade"); err != nil {
+		os.Exit(2)
+	}
+
+	os.Stdout.WriteString("locked\n")
+
+	// Ждем, пока родитель проверит блокировку, и выходим без unlock
+	buffer := make([]byte, 1)
+	os.Stdin.Read(buffer)
+
+	os.Exit(0)
+}
+
+func TestLock(t *testing.T) {
+	locker := NewLocker(t.TempDir(), "config.json")
+
+	unlock, err := locker.Lock("job-Trade")
+
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+
+	if _, err := locker.Lock("job-Trade"); err == nil || !strings.Contains(err.Error(), "locked by pid") {
+		t.Fatalf("err = %v, want job locked by this process", err)
+	}
+
+	// Другие имена независимы
+	other, err := locker.Lock("prune")
+
+	if err != nil {
+		t.Fatalf("Lock other: %v", err)
+	}
+
+	other()
+	unlock()
+
+	unlock, err = locker.Lock("job-Trade")
+
+	if err != nil {
+		t.Fatalf("Lock after unlock: %v", err)
+	}
+
+	unlock()
+}
+
+func TestLockReleasedOnExit(t *testing.T) {
+	dir := t.TempDir()
+
+	command := exec.Command(os.Args[0], "-test.run=^TestHoldAndExit$")
+	command.Env = append(os.Environ(), "LOCK_TEST_DIR="+dir)
+
+	stdin, _ := command.StdinPipe()
+	stdout, _ := command.StdoutPipe()
+
+	if err := command.Start(); err != nil {
+		t.Fatalf("unable to start helper: %v", err)
+	}
+
+	buffer := make([]byte, len("locked\n"))
+
+	if _, err := stdout.Read(buffer); err != nil || string(buffer) != "locked\n" {
+		t.Fatalf("helper did not lock: %q, %v", buffer, err)
+	}
+
+	locker := NewLocker(dir, "config.json")
+
+	if _, err := locker.Lock("job-Trade"); err == nil {
+		t.Fatalf("lock of a live process is taken over")
+	}
+
+	// Процесс завершается без unlock, блокировку снимает система
+	stdin.Close()
+	command.Wait()
+
+	unlock, err := locker.Wait("job-Trade", 5*time.Second)
+
+	if err != nil {
+		t.Fatalf("lock of a dead process is not released: %v", err)
+	}
+
+	unlock()
+}
@@ -0,0 +1,197 @@
+package nas
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	entity "yd_backup/internal/models"
+	"yd_backup/internal/repo"
+)
+
+type BackupNas struct {
+	destination entity.Destination
+	protected   func(path string) bool
+	uploads     repo.UploadFolders
+}
+
+func NewBackupNas(destination entity.Destination) *BackupNas {
+	return &BackupNas{destination: destination}
+}
+
+// SetProtected задает проверку копий, которые нельзя удалять при очистке
+func (b *BackupNas) SetProtected(protected func(path string) bool) {
+	b.protected = protected
+}
+
+func (b *BackupNas) CreateFolder() error {
+	if err := os.MkdirAll(b.destination.Dir, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create target directory %s: %v", b.destination.Dir, err)
+	}
+
+	return nil
+}
+
+// UploadBackup атомарно копирует файл в целевую директорию под путем name,
+// создавая недостающие поддиректории
+func (b *BackupNas) UploadBackup(backupPath string, name string) error {
+	defer b.uploads.Enter(name)()
+
+	targetPath := b.RemotePath(name)
+
+	if err := os.MkdirAll(filepath.Dir(targetPath), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create directory %s: %v", filepath.Dir(targetPath), err)
+	}
+
+	return repo.AtomicCopy(backupPath, targetPath)
+}
+
+// RemotePath возвращает путь копии по пути name относительно целевой директории
+func (b *BackupNas) RemotePath(name string) string {
+	return filepath.Join(b.destination.Dir, filepath.FromSlash(name))
+}
+
+// UploadCatalog атомарно записывает копию каталога в целевую директорию
+func (b *BackupNas) UploadCatalog(data []byte) error {
+	return repo.AtomicWrite(filepath.Join(b.destination.Dir, entity.CatalogName), bytes.NewReader(data))
+}
+
+func (b *BackupNas) DownloadBackup(name string, targetPath string) error {
+	return repo.AtomicCopy(b.RemotePath(name), targetPath)
+}
+
+// DownloadCatalog читает копию каталога из целевой директории. Отсутствие
+// копии не считается ошибкой
+func (b *BackupNas) DownloadCatalog() ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(b.destination.Dir, entity.CatalogName))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	return data, err
+}
+
+// ListBackup возвращает файлы целевой директории и ее поддиректорий. Имя
+// файла - путь относительно целевой директории через /
+func (b *BackupNas) ListBackup() ([]entity.BackupFile, error) {
+	var result []entity.BackupFile
+
+	err := b.walk(func(name string, filePath string, fileInfo os.FileInfo) error {
+		if strings.HasSuffix(name, repo.TmpSuffix) {
+			return nil
+		}
+
+		result = append(result, entity.BackupFile{
+			Name:    name,
+			Path:    filePath,
+			Size:    fileInfo.Size(),
+			Created: fileInfo.ModTime(),
+		})
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Created.Before(result[j].Created)
+	})
+
+	return result, nil
+}
+
+func (b *BackupNas) RemoveBackup() ([]string, error) {
+	var result []string
+
+	err := b.walk(func(name string, path string, fileInfo os.FileInfo) error {
+		if b.protected != nil && b.protected(path) {
+			return nil
+		}
+
+		if fileInfo.ModTime().Add(b.destination.Expired.Duration).Before(time.Now()) {
+
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("unable to remove file %s", path)
+			}
+
+			result = append(result, path)
+		}
+
+		return nil
+	})
+
+	b.removeEmptyDirs(result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// walk обходит файлы копий в целевой директории и ее поддиректориях,
+// пропуская служебные файлы
+func (b *BackupNas) walk(visit func(name string, path string, fileInfo os.FileInfo) error) error {
+	err := filepath.WalkDir(b.destination.Dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() || entity.IsServiceFile(entry.Name()) {
+			return nil
+		}
+
+		fileInfo, err := entry.Info()
+
+		if err != nil {
+			return fmt.Errorf("unable to get file info %s", entry.Name())
+		}
+
+		name, err := filepath.Rel(b.destination.Dir, path)
+
+		if err != nil {
+			return err
+		}
+
+		return visit(filepath.ToSlash(name), path, fileInfo)
+	})
+
+	if err != nil {
+		return fmt.Errorf("unable to read target directory %s: %v", b.destination.Dir, err)
+	}
+
+	return nil
+}
+
+// removeEmptyDirs удаляет поддиректории удаленных файлов, оставшиеся
+// пустыми. Сама целевая директория и директории, в которые сейчас идут
+// загрузки, не удаляются
+func (b *BackupNas) removeEmptyDirs(paths []string) {
+	for _, removedPath := range paths {
+		for dir := filepath.Dir(removedPath); ; dir = filepath.Dir(dir) {
+			rel, err := filepath.Rel(b.destination.Dir, dir)
+
+			if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+				break
+			}
+
+			// Непустая или занятая загрузкой директория не удаляется, и выше
+			// подниматься незачем
+			removed, err := b.uploads.Remove(filepath.ToSlash(rel), func() error {
+				return os.Remove(dir)
+			})
+
+			if !removed || err != nil {
+				break
+			}
+		}
+	}
+}
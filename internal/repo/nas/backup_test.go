@@ -0,0 +1,82 @@
+package nas
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	entity "yd_backup/internal/models"
+)
+
+func TestRemoveBackupKeepsUploadFolder(t *testing.T) {
+	dir := t.TempDir()
+
+	backup := NewBackupNas(entity.Destination{
+		Name:    "nas",
+		Dir:     dir,
+		Expired: entity.Duration{Duration: time.Hour},
+	})
+
+	old := filepath.Join(dir, "Trade", "2026", "Trade_20260101_120000.zip")
+
+	if err := os.MkdirAll(filepath.Dir(old), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(old, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	expired := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(old, expired, expired)
+
+	// Загрузка новой копии в ту же папку уже началась, но файл еще не записан
+	leave := backup.uploads.Enter("Trade/2026/Trade_20260102_120000.zip")
+
+	removed, err := backup.RemoveBackup()
+
+	if err != nil || len(removed) != 1 {
+		t.Fatalf("RemoveBackup = %v, %v, want old copy removed", removed, err)
+	}
+
+	if _, err := os.Stat(filepath.Dir(old)); err != nil {
+		t.Errorf("folder of the running upload is removed: %v", err)
+	}
+
+	leave()
+
+	// Без загрузки пустые папки удаляются до целевой директории
+	backup.removeEmptyDirs(removed)
+
+	if _, err := os.Stat(filepath.Join(dir, "Trade")); !os.IsNotExist(err) {
+		t.Errorf("empty folder is kept after upload: %v", err)
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("target directory is removed: %v", err)
+	}
+}
+
+func TestUploadBackup(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(t.TempDir(), "Trade_20260101_120000.zip")
+
+	if err := os.WriteFile(source, []byte("backup"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	backup := NewBackupNas(entity.Destination{Name: "nas", Dir: dir})
+
+	if err := backup.UploadBackup(source, "Trade/2026/Trade_20260101_120000.zip"); err != nil {
+		t.Fatalf("UploadBackup: %v", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dir, "Trade", "2026", "Trade_20260101_120000.zip")); string(data) != "backup" {
+		t.Errorf("uploaded = %q, want copy of the source", data)
+	}
+
+	// После загрузки папка больше не занята
+	if removed, _ := backup.uploads.Remove("Trade/2026", func() error { return nil }); !removed {
+		t.Errorf("folder is still busy after upload")
+	}
+}
@@ -0,0 +1,183 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"yd_backup/internal/models"
+	"yd_backup/internal/repo"
+	"yd_backup/internal/repo/lock"
+)
+
+// Journal - файл неподтвержденных загрузок. Файл перечитывается перед
+// каждой операцией, чтобы демон и ручные запуски видели изменения друг друга
+type Journal struct {
+	mu     sync.Mutex
+	path   string
+	locker *lock.Locker
+}
+
+func NewJournal(path string) *Journal {
+	return &Journal{path: path}
+}
+
+// SetLocker включает блокировку файла журнала между процессами, например демоном
+// и ручным запуском с той же конфигурацией
+func (j *Journal) SetLocker(locker *lock.Locker) {
+	j.locker = locker
+}
+
+// lockFile захватывает блокировку файла между процессами на время
+// чтения-изменения-записи. Без locker остается только мьютекс процесса
+func (j *Journal) lockFile() (func(), error) {
+	if j.locker == nil {
+		return func() {}, nil
+	}
+
+	return j.locker.Wait("queue", lock.FileTimeout)
+}
+
+// Add добавляет загрузку или обновляет запись с тем же путем и назначением
+func (j *Journal) Add(upload models.Upload) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	unlock, err := j.lockFile()
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	uploads, err := j.read()
+
+	if err != nil {
+		return err
+	}
+
+	for i := range uploads {
+		if uploads[i].Path == upload.Path && uploads[i].Destination == upload.Destination {
+			uploads[i] = upload
+			return j.write(uploads)
+		}
+	}
+
+	return j.write(append(uploads, upload))
+}
+
+// Remove удаляет подтвержденную загрузку
+func (j *Journal) Remove(path string, destination string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	unlock, err := j.lockFile()
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	uploads, err := j.read()
+
+	if err != nil {
+		return err
+	}
+
+	result := uploads[:0]
+
+	for _, upload := range uploads {
+		if upload.Path != path || upload.Destination != destination {
+			result = append(result, upload)
+		}
+	}
+
+	return j.write(result)
+}
+
+// Pending возвращает неподтвержденные загрузки, начиная с самых старых
+func (j *Journal) Pending() ([]models.Upload, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	uploads, err := j.read()
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(uploads, func(i, k int) bool {
+		return uploads[i].Created.Before(uploads[k].Created)
+	})
+
+	return uploads, nil
+}
+
+// IsPending сообщает, есть ли неподтвержденные загрузки локальной копии.
+// При ошибке чтения журнала копия считается неподтвержденной
+func (j *Journal) IsPending(path string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	uploads, err := j.read()
+
+	if err != nil {
+		return true
+	}
+
+	for _, upload := range uploads {
+		if filepath.Clean(upload.Path) == filepath.Clean(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (j *Journal) read() ([]models.Upload, error) {
+	var uploads []models.Upload
+
+	data, err := os.ReadFile(j.path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read upload journal %s: %v", j.path, err)
+	}
+
+	if err := json.Unmarshal(data, &uploads); err != nil {
+		return nil, fmt.Errorf("unable to parse upload journal %s: %v", j.path, err)
+	}
+
+	return uploads, nil
+}
+
+func (j *Journal) write(uploads []models.Upload) error {
+	if uploads == nil {
+		uploads = []models.Upload{}
+	}
+
+	data, err := json.MarshalIndent(uploads, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.path), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create upload journal dir: %v", err)
+	}
+
+	if err := repo.AtomicWrite(j.path, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("unable to write upload journal %s: %v", j.path, err)
+	}
+
+	return nil
+}
@@ -1,74 +1,218 @@
 package remote
 
 import (
+	"errors"
 	"fmt"
-	"path/filepath"
-	"strings"
+	"path"
+	"sort"
+	"sync"
 	"time"
 	entity "yd_backup/internal/models"
+	"yd_backup/internal/repo"
+	"yd_backup/internal/repo/credentials"
 	"yd_backup/pkg/yandex/disk"
 	"yd_backup/pkg/yandex/disk/models"
+	"yd_backup/pkg/yandex/oauth"
 )
 
 type BackupRemote struct {
-	disk    *disk.YandexDisk
-	setting entity.Setting
+	disk        *disk.YandexDisk
+	destination entity.Destination
+	protected   func(path string) bool
+	folders     map[string]bool
+	foldersMu   sync.Mutex
+	uploads     repo.UploadFolders
+}
+
+const pageLimit = 100
+
+// SetProtected задает проверку копий, которые нельзя удалять при очистке
+func (b *BackupRemote) SetProtected(protected func(path string) bool) {
+	b.protected = protected
 }
 
 func (b *BackupRemote) RemoveBackup() ([]string, error) {
 	var result []string
 
-	resource, err := b.disk.GetResource(models.Params{Path: b.setting.Yandex.Dir})
+	files, err := b.ListBackup()
 
 	if err != nil {
 		return nil, err
 	}
 
-	for _, resource := range resource.Embedded.Items {
+	for _, file := range files {
+		if file.Pinned || (b.protected != nil && b.protected(file.Path)) {
+			continue
+		}
+
+		created := file.Created
+
+		// Время загрузки позже времени копии, если она догружалась из журнала
+		if file.Metadata != nil && !file.Metadata.Created.IsZero() {
+			created = file.Metadata.Created
+		}
 
-		if resource.Created.Local().Add(b.setting.Backup.Expired.Duration).Before(time.Now()) {
+		if created.Local().Add(b.destination.Expired.Duration).Before(time.Now()) {
 
 			var params models.Params
 
-			params.Path = resource.Path
-			params.Permanently = true
+			params.Path = file.Path
+			params.Permanently = b.destination.Delete != entity.DeleteTrash
 
 			if _, err := b.disk.RemoveResource(params); err != nil {
 				return nil, err
 			}
 
-			result = append(result, resource.Path)
+			result = append(result, file.Path)
 		}
 
 	}
 
+	b.removeEmptyFolders(result)
+
 	return result, nil
 
 }
 
-func NewBackupRemote(setting entity.Setting) *BackupRemote {
-	return &BackupRemote{
-		setting: setting,
-		disk:    disk.NewBackupYandex(setting.Yandex.Token, setting.Yandex.Timeout.Duration),
+// ListBackup возвращает файлы папки назначения и ее подпапок. Имя файла -
+// путь относительно папки назначения
+func (b *BackupRemote) ListBackup() ([]entity.BackupFile, error) {
+	result, err := b.listFolder("")
+
+	if err != nil {
+		return nil, err
 	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Created.Before(result[j].Created)
+	})
+
+	return result, nil
 }
 
-func (b *BackupRemote) CreateFolder(dir string) error {
-	return nil
+// listFolder возвращает файлы подпапки folder постранично, чтобы не
+// упираться в лимит элементов одного ответа API
+func (b *BackupRemote) listFolder(folder string) ([]entity.BackupFile, error) {
+	var result []entity.BackupFile
+
+	for offset := 0; ; offset += pageLimit {
+		resource, err := b.disk.GetResource(models.Params{
+			Path:   b.RemotePath(folder),
+			Limit:  pageLimit,
+			Offset: offset,
+			Sort:   "created",
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range resource.Embedded.Items {
+			name := path.Join(folder, item.Name)
+
+			if item.Type == "dir" {
+				files, err := b.listFolder(name)
+
+				if err != nil {
+					return nil, err
+				}
+
+				result = append(result, files...)
+				continue
+			}
+
+			if entity.IsServiceFile(item.Name) {
+				continue
+			}
+
+			result = append(result, entity.BackupFile{
+				Name:     name,
+				Path:     item.Path,
+				Size:     int64(item.Size),
+				Created:  item.Created,
+				Md5:      item.Md5,
+				Pinned:   isPinned(item),
+				Metadata: readMetadata(item),
+			})
+		}
+
+		if len(resource.Embedded.Items) < pageLimit {
+			break
+		}
+	}
+
+	return result, nil
 }
 
-func (b *BackupRemote) UploadBackup(backupPath string) error {
+func (b *BackupRemote) DownloadBackup(name string, targetPath string) error {
 	var params models.Params
 
-	var remoteFileName = filepath.Base(backupPath)
+	params.Path = b.RemotePath(name)
+
+	link, err := b.disk.DownloadLink(params)
 
-	if !b.setting.Yandex.Extension {
-		remoteFileName = strings.TrimSuffix(remoteFileName, filepath.Ext(backupPath))
+	if err != nil {
+		return err
 	}
 
-	remotePath := fmt.Sprintf("%s/%s", b.setting.Yandex.Dir, remoteFileName)
+	return b.disk.DownloadFile(link, targetPath)
+}
+
+// Clients - клиенты Yandex Disk по именам аккаунтов. У каждого аккаунта
+// свой клиент со своим пулом соединений, токеном и таймаутом
+type Clients map[string]*disk.YandexDisk
+
+// NewClients создает клиентов аккаунтов. Токен из файла credentials имеет
+// приоритет над токеном из конфигурации; если у аккаунта задан client_id,
+// клиент сам обновляет токен при ответе 401
+func NewClients(accounts []entity.Account, store *credentials.Store) Clients {
+	clients := make(Clients, len(accounts))
+
+	for _, account := range accounts {
+		token := account.Token.Value()
+
+		if stored, ok := store.Get(account.Name); ok && stored.AccessToken != "" {
+			token = stored.AccessToken
+		}
+
+		client := disk.NewBackupYandex(token, account.Timeout.Duration)
+
+		if account.ClientID != "" {
+			client.SetRefresher(credentials.NewRefresher(store, account.Name,
+				oauth.NewClient(account.ClientID, account.ClientSecret.Value())))
+		}
 
-	params.Path = remotePath
+		clients[account.Name] = client
+	}
+
+	return clients
+}
+
+func NewBackupRemote(clients Clients, destination entity.Destination) (*BackupRemote, error) {
+	client, ok := clients[destination.Account]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown account %s of destination %s", destination.Account, destination.Name)
+	}
+
+	return &BackupRemote{
+		destination: destination,
+		disk:        client,
+		folders:     make(map[string]bool),
+	}, nil
+}
+
+// UploadBackup загружает копию под путем name, создавая недостающие подпапки
+func (b *BackupRemote) UploadBackup(backupPath string, name string) error {
+	defer b.uploads.Enter(name)()
+
+	if err := b.createFolders(name); err != nil {
+		return err
+	}
+
+	var params models.Params
+
+	params.Path = b.RemotePath(name)
 	params.Overwrite = true
 
 	link, err := b.disk.CreateLink(params)
@@ -79,6 +223,64 @@ func (b *BackupRemote) UploadBackup(backupPath string) error {
 	return b.disk.UploadFile(link, backupPath)
 }
 
+// RemotePath возвращает путь Disk по пути name относительно папки назначения
+func (b *BackupRemote) RemotePath(name string) string {
+	if name == "" {
+		return b.destination.Dir
+	}
+
+	return fmt.Sprintf("%s/%s", b.destination.Dir, name)
+}
+
+// UploadCatalog записывает копию каталога в папку назначения
+func (b *BackupRemote) UploadCatalog(data []byte) error {
+	link, err := b.disk.CreateLink(models.Params{
+		Path:      fmt.Sprintf("%s/%s", b.destination.Dir, entity.CatalogName),
+		Overwrite: true,
+	})
+
+	if err != nil {
+		return fmt.Errorf("unable to upload catalog: %v", err)
+	}
+
+	if err := b.disk.UploadData(link, data); err != nil {
+		return fmt.Errorf("unable to upload catalog: %v", err)
+	}
+
+	return nil
+}
+
+// DownloadCatalog читает копию каталога из папки назначения. Отсутствие
+// копии не считается ошибкой
+func (b *BackupRemote) DownloadCatalog() ([]byte, error) {
+	link, err := b.disk.DownloadLink(models.Params{
+		Path: fmt.Sprintf("%s/%s", b.destination.Dir, entity.CatalogName),
+	})
+
+	var responseError *models.ResponseError
+
+	if errors.As(err, &responseError) && responseError.StatusCode == 404 {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to download catalog: %v", err)
+	}
+
+	return b.disk.DownloadData(link)
+}
+
 func (b *BackupRemote) EraseBackup() error {
 	return nil
 }
+
+// Quota возвращает место на диске аккаунта назначения
+func (b *BackupRemote) Quota() (entity.Quota, error) {
+	info, err := b.disk.GetDisk()
+
+	if err != nil {
+		return entity.Quota{}, err
+	}
+
+	return entity.Quota{Total: info.TotalSpace, Used: info.UsedSpace, Trash: info.TrashSize}, nil
+}
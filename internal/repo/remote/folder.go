@@ -0,0 +1,176 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"yd_backup/pkg/yandex/disk/models"
+)
+
+// existentDirectoryError - тип ошибки 409 Yandex Disk для уже существующей
+// папки. Тот же код 409 возвращается и при отсутствии родительской папки
+const existentDirectoryError = "DiskPathPointsToExistentDirectoryError"
+
+// CreateFolder создает папку назначения и недостающие родительские папки
+func (b *BackupRemote) CreateFolder() error {
+	dir := strings.TrimSuffix(b.destination.Dir, "/")
+
+	if dir == "" || b.isCreated("") {
+		return nil
+	}
+
+	var folders []string
+
+	for i := range dir {
+		if dir[i] != '/' {
+			continue
+		}
+
+		// Корень диска и префикс disk: не создаются
+		if parent := strings.Trim(strings.TrimPrefix(dir[:i], "disk:"), "/"); parent != "" {
+			folders = append(folders, dir[:i])
+		}
+	}
+
+	for _, folder := range append(folders, dir) {
+		if err := b.createFolder(folder); err != nil {
+			return err
+		}
+	}
+
+	b.setCreated("")
+
+	return nil
+}
+
+// createFolders создает папку назначения и подпапки пути name, которых еще
+// нет, от внешних к вложенным. Созданные папки запоминаются, чтобы не
+// запрашивать их при каждой загрузке
+func (b *BackupRemote) createFolders(name string) error {
+	if err := b.CreateFolder(); err != nil {
+		return err
+	}
+
+	var folder string
+
+	for _, part := range strings.Split(path.Dir(name), "/") {
+		if part == "." {
+			break
+		}
+
+		folder = path.Join(folder, part)
+
+		if b.isCreated(folder) {
+			continue
+		}
+
+		if err := b.createFolder(b.RemotePath(folder)); err != nil {
+			return err
+		}
+
+		b.setCreated(folder)
+	}
+
+	return nil
+}
+
+// createFolder создает папку diskPath. Уже существующая папка ошибкой не
+// считается, остальные ответы 409, например об отсутствии родителя, - считаются
+func (b *BackupRemote) createFolder(diskPath string) error {
+	_, err := b.disk.CreateResource(models.Params{Path: diskPath})
+
+	var responseError *models.ResponseError
+
+	if errors.As(err, &responseError) && responseError.ErrorType == existentDirectoryError {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to create folder %s: %v", diskPath, err)
+	}
+
+	return nil
+}
+
+func (b *BackupRemote) isCreated(folder string) bool {
+	b.foldersMu.Lock()
+	defer b.foldersMu.Unlock()
+
+	return b.folders[folder]
+}
+
+func (b *BackupRemote) setCreated(folder string) {
+	b.foldersMu.Lock()
+	defer b.foldersMu.Unlock()
+
+	b.folders[folder] = true
+}
+
+// removeEmptyFolders удаляет подпапки удаленных файлов, оставшиеся пустыми,
+// от вложенных к внешним. Сама папка назначения и папки, в которые сейчас
+// идут загрузки, не удаляются
+func (b *BackupRemote) removeEmptyFolders(paths []string) {
+	folders := make(map[string]bool)
+
+	for _, removedPath := range paths {
+		name, ok := b.relativeName(removedPath)
+
+		if !ok {
+			continue
+		}
+
+		for folder := path.Dir(name); folder != "."; folder = path.Dir(folder) {
+			folders[folder] = true
+		}
+	}
+
+	var sorted []string
+
+	for folder := range folders {
+		sorted = append(sorted, folder)
+	}
+
+	// Более глубокие папки удаляются раньше родительских
+	sort.Slice(sorted, func(i, j int) bool {
+		return strings.Count(sorted[i], "/") > strings.Count(sorted[j], "/")
+	})
+
+	for _, folder := range sorted {
+		b.uploads.Remove(folder, func() error {
+			return b.removeEmptyFolder(folder)
+		})
+	}
+}
+
+func (b *BackupRemote) removeEmptyFolder(folder string) error {
+	resource, err := b.disk.GetResource(models.Params{Path: b.RemotePath(folder), Limit: 1})
+
+	if err != nil {
+		return err
+	}
+
+	if len(resource.Embedded.Items) > 0 {
+		return nil
+	}
+
+	if _, err := b.disk.RemoveResource(models.Params{Path: b.RemotePath(folder), Permanently: true}); err != nil {
+		return err
+	}
+
+	b.foldersMu.Lock()
+	delete(b.folders, folder)
+	b.foldersMu.Unlock()
+
+	return nil
+}
+
+// relativeName возвращает путь файла Disk относительно папки назначения
+func (b *BackupRemote) relativeName(diskPath string) (string, bool) {
+	if !b.ownsPath(diskPath) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(normalizePath(diskPath), normalizePath(b.destination.Dir)+"/"), true
+}
@@ -0,0 +1,112 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	entity "yd_backup/internal/models"
+	"yd_backup/pkg/yandex/disk/models"
+)
+
+// AcquireLease захватывает или продлевает аренду папки назначения. Чужая
+// неистекшая аренда - ошибка. После записи аренда перечитывается, чтобы
+// заметить хост, записавший свою аренду одновременно с нами
+func (b *BackupRemote) AcquireLease(lease entity.Lease) error {
+	current, err := b.readLease()
+
+	if err != nil {
+		return err
+	}
+
+	if current != nil && current.Owner != lease.Owner && current.Expires.After(time.Now()) {
+		return fmt.Errorf("folder %s is leased by %s until %s", b.destination.Dir, current.Owner,
+			current.Expires.Local().Format(time.DateTime))
+	}
+
+	if err := b.writeLease(lease); err != nil {
+		return err
+	}
+
+	current, err = b.readLease()
+
+	if err != nil {
+		return err
+	}
+
+	if current == nil || current.Owner != lease.Owner {
+		return fmt.Errorf("lease of folder %s was taken by another host", b.destination.Dir)
+	}
+
+	return nil
+}
+
+// ReleaseLease удаляет аренду, если она принадлежит владельцу lease
+func (b *BackupRemote) ReleaseLease(lease entity.Lease) error {
+	current, err := b.readLease()
+
+	if err != nil || current == nil || current.Owner != lease.Owner {
+		return err
+	}
+
+	var params models.Params
+
+	params.Path = b.leasePath()
+	params.Permanently = true
+
+	_, err = b.disk.RemoveResource(params)
+
+	return err
+}
+
+func (b *BackupRemote) leasePath() string {
+	return fmt.Sprintf("%s/%s", b.destination.Dir, entity.LeaseName)
+}
+
+func (b *BackupRemote) readLease() (*entity.Lease, error) {
+	link, err := b.disk.DownloadLink(models.Params{Path: b.leasePath()})
+
+	var responseError *models.ResponseError
+
+	if errors.As(err, &responseError) && responseError.StatusCode == 404 {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read lease: %v", err)
+	}
+
+	data, err := b.disk.DownloadData(link)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read lease: %v", err)
+	}
+
+	var lease entity.Lease
+
+	if err := json.Unmarshal(data, &lease); err != nil {
+		return nil, fmt.Errorf("unable to parse lease: %v", err)
+	}
+
+	return &lease, nil
+}
+
+func (b *BackupRemote) writeLease(lease entity.Lease) error {
+	data, err := json.Marshal(lease)
+
+	if err != nil {
+		return err
+	}
+
+	link, err := b.disk.CreateLink(models.Params{Path: b.leasePath(), Overwrite: true})
+
+	if err != nil {
+		return fmt.Errorf("unable to write lease: %v", err)
+	}
+
+	if err := b.disk.UploadData(link, data); err != nil {
+		return fmt.Errorf("unable to write lease: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package remote
+
+import (
+	"encoding/json"
+	entity "yd_backup/internal/models"
+	"yd_backup/pkg/yandex/disk/models"
+)
+
+// metadataProperty - свойство custom_properties с описанием копии
+const metadataProperty = "yd_backup"
+
+// SetMetadata записывает описание копии name в custom_properties файла в
+// папке назначения
+func (b *BackupRemote) SetMetadata(name string, metadata entity.BackupMetadata) error {
+	_, err := b.disk.PatchResource(models.Params{
+		Path:   b.RemotePath(name),
+		Fields: []string{"name"},
+	}, map[string]interface{}{metadataProperty: metadata})
+
+	return err
+}
+
+// readMetadata возвращает описание копии из custom_properties ресурса или
+// nil, если копия загружена без него
+func readMetadata(item models.Resource) *entity.BackupMetadata {
+	properties, ok := item.CustomProperties.(map[string]interface{})
+
+	if !ok || properties[metadataProperty] == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(properties[metadataProperty])
+
+	if err != nil {
+		return nil
+	}
+
+	var metadata entity.BackupMetadata
+
+	if err := json.Unmarshal(data, &metadata); err != nil || metadata.ID == "" {
+		return nil
+	}
+
+	return &metadata
+}
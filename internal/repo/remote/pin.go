@@ -0,0 +1,53 @@
+package remote
+
+import (
+	"time"
+	"yd_backup/pkg/yandex/disk/models"
+)
+
+// Свойства закрепленной копии в custom_properties ресурса Yandex Disk
+const (
+	pinnedProperty    = "yd_backup_pinned"
+	pinnedAtProperty  = "yd_backup_pinned_at"
+	pinReasonProperty = "yd_backup_pin_reason"
+)
+
+// SetPinned закрепляет копию name в папке назначения или снимает
+// закрепление. Закрепленная копия пропускается очисткой, даже если каталог
+// потерян
+func (b *BackupRemote) SetPinned(name string, pinned bool, reason string) error {
+	properties := map[string]interface{}{
+		pinnedProperty:    nil,
+		pinnedAtProperty:  nil,
+		pinReasonProperty: nil,
+	}
+
+	if pinned {
+		properties[pinnedProperty] = true
+		properties[pinnedAtProperty] = time.Now().UTC().Format(time.RFC3339)
+
+		if reason != "" {
+			properties[pinReasonProperty] = reason
+		}
+	}
+
+	_, err := b.disk.PatchResource(models.Params{
+		Path:   b.RemotePath(name),
+		Fields: []string{"name", "custom_properties"},
+	}, properties)
+
+	return err
+}
+
+// isPinned сообщает, что ресурс закреплен через custom_properties
+func isPinned(item models.Resource) bool {
+	properties, ok := item.CustomProperties.(map[string]interface{})
+
+	if !ok {
+		return false
+	}
+
+	pinned, _ := properties[pinnedProperty].(bool)
+
+	return pinned
+}
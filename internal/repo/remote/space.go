@@ -0,0 +1,50 @@
+package remote
+
+import (
+	"strings"
+	"yd_backup/pkg/yandex/disk/models"
+)
+
+// RemoveFile безвозвратно удаляет одну копию из папки назначения: копия в
+// корзине продолжает занимать место
+func (b *BackupRemote) RemoveFile(filePath string) error {
+	if _, err := b.disk.RemoveResource(models.Params{Path: filePath, Permanently: true}); err != nil {
+		return err
+	}
+
+	b.removeEmptyFolders([]string{filePath})
+
+	return nil
+}
+
+// EmptyTrash удаляет из корзины файлы, попавшие туда из папки назначения,
+// и возвращает освобожденное место. Чужие файлы в корзине не трогаются
+func (b *BackupRemote) EmptyTrash() (int64, error) {
+	items, err := b.ListTrash()
+
+	if err != nil {
+		return 0, err
+	}
+
+	var freed int64
+
+	for _, item := range items {
+		if _, err := b.disk.RemoveTrash(models.Params{Path: item.Path}); err != nil {
+			return freed, err
+		}
+
+		freed += item.Size
+	}
+
+	return freed, nil
+}
+
+// ownsPath сообщает, что путь Disk лежит в папке назначения
+func (b *BackupRemote) ownsPath(diskPath string) bool {
+	return strings.HasPrefix(normalizePath(diskPath), normalizePath(b.destination.Dir)+"/")
+}
+
+// normalizePath убирает префикс disk: и ведущий слэш
+func normalizePath(diskPath string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(diskPath, "disk:"), "/")
+}
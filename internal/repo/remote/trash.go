@@ -0,0 +1,118 @@
+package remote
+
+import (
+	"fmt"
+	"time"
+	entity "yd_backup/internal/models"
+	"yd_backup/pkg/yandex/disk/models"
+)
+
+// ListTrash возвращает файлы корзины, удаленные из папки назначения,
+// постранично и от старых к новым
+func (b *BackupRemote) ListTrash() ([]entity.TrashFile, error) {
+	var result []entity.TrashFile
+
+	for offset := 0; ; offset += pageLimit {
+		resource, err := b.disk.GetTrash(models.Params{Limit: pageLimit, Offset: offset, Sort: "deleted"})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range resource.Embedded.Items {
+			if item.Type == "dir" || !b.ownsPath(item.OriginPath) {
+				continue
+			}
+
+			result = append(result, entity.TrashFile{
+				Name:       item.Name,
+				Path:       item.Path,
+				OriginPath: item.OriginPath,
+				Size:       int64(item.Size),
+				Deleted:    item.Deleted,
+			})
+		}
+
+		if len(resource.Embedded.Items) < pageLimit {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// RestoreTrash восстанавливает копию name из корзины на прежнее место в
+// папке назначения и возвращает ее путь. Существующий файл не перезаписывается
+func (b *BackupRemote) RestoreTrash(name string) (string, error) {
+	item, err := b.findTrash(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := b.disk.RestoreTrash(models.Params{Path: item.Path}); err != nil {
+		return "", err
+	}
+
+	return item.OriginPath, nil
+}
+
+// RemoveTrash безвозвратно удаляет копию name из корзины
+func (b *BackupRemote) RemoveTrash(name string) error {
+	item, err := b.findTrash(name)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = b.disk.RemoveTrash(models.Params{Path: item.Path})
+
+	return err
+}
+
+// PurgeTrash безвозвратно удаляет из корзины копии назначения, удаленные
+// раньше grace назад, и возвращает их исходные пути
+func (b *BackupRemote) PurgeTrash(grace time.Duration) ([]string, error) {
+	items, err := b.ListTrash()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+
+	for _, item := range items {
+		if item.Deleted.Add(grace).After(time.Now()) {
+			continue
+		}
+
+		if _, err := b.disk.RemoveTrash(models.Params{Path: item.Path}); err != nil {
+			return result, err
+		}
+
+		result = append(result, item.OriginPath)
+	}
+
+	return result, nil
+}
+
+// findTrash ищет в корзине копию назначения по пути в корзине, исходному
+// пути или пути относительно папки назначения. Из нескольких копий с одним
+// путем выбирается удаленная последней
+func (b *BackupRemote) findTrash(name string) (entity.TrashFile, error) {
+	items, err := b.ListTrash()
+
+	if err != nil {
+		return entity.TrashFile{}, err
+	}
+
+	for i := len(items) - 1; i >= 0; i-- {
+		relative, _ := b.relativeName(items[i].OriginPath)
+
+		if items[i].Path == name || normalizePath(items[i].OriginPath) == normalizePath(name) || relative == name {
+			return items[i], nil
+		}
+	}
+
+	return entity.TrashFile{}, fmt.Errorf("%s is not in trash of destination %s", name, b.destination.Name)
+}
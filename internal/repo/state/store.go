@@ -0,0 +1,135 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"yd_backup/internal/models"
+	"yd_backup/internal/repo"
+	"yd_backup/internal/repo/lock"
+)
+
+// Store - файл состояния заданий режима демона
+type Store struct {
+	mu     sync.Mutex
+	path   string
+	locker *lock.Locker
+	jobs   map[string]models.JobState
+}
+
+func NewStore(path string) *Store {
+	return &Store{
+		path: path,
+		jobs: make(map[string]models.JobState),
+	}
+}
+
+// SetLocker включает блокировку файла состояния между процессами, например демоном
+// и ручным запуском с той же конфигурацией
+func (s *Store) SetLocker(locker *lock.Locker) {
+	s.locker = locker
+}
+
+// lockFile захватывает блокировку файла между процессами на время
+// чтения-изменения-записи. Без locker остается только мьютекс процесса
+func (s *Store) lockFile() (func(), error) {
+	if s.locker == nil {
+		return func() {}, nil
+	}
+
+	return s.locker.Wait("state", lock.FileTimeout)
+}
+
+// Load читает файл состояния. Отсутствие файла не считается ошибкой
+func (s *Store) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs, err := s.read()
+
+	if err != nil {
+		return err
+	}
+
+	s.jobs = jobs
+
+	return nil
+}
+
+func (s *Store) Get(job string) (models.JobState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.jobs[job]
+
+	return state, ok
+}
+
+// Set сохраняет состояние задания и сразу записывает файл. Файл
+// перечитывается под блокировкой, чтобы не затереть состояние других заданий,
+// записанное другим процессом
+func (s *Store) Set(job string, state models.JobState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lockFile()
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	jobs, err := s.read()
+
+	if err != nil {
+		return err
+	}
+
+	jobs[job] = state
+	s.jobs = jobs
+
+	data, err := json.MarshalIndent(s.jobs, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create state dir: %v", err)
+	}
+
+	if err := repo.AtomicWrite(s.path, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("unable to write state file %s: %v", s.path, err)
+	}
+
+	return nil
+}
+
+func (s *Store) read() (map[string]models.JobState, error) {
+	jobs := make(map[string]models.JobState)
+
+	data, err := os.ReadFile(s.path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return jobs, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read state file %s: %v", s.path, err)
+	}
+
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("unable to parse state file %s: %v", s.path, err)
+	}
+
+	if jobs == nil {
+		jobs = make(map[string]models.JobState)
+	}
+
+	return jobs, nil
+}
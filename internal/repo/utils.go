@@ -48,12 +48,12 @@ func (rp *Piper) Read(p []byte) (int, error) {
 		percentage := float64(rp.rtotal) / float64(rp.length) * 100
 
 		if percentage-rp.progress > 2 {
-			fmt.Printf("Чтение файла %s: процент чтения: %.2f%%, объем прочитанных данных: %d МБайт\n", rp.name, percentage, rp.rtotal/1024/1024)
+			fmt.Fprintf(os.Stderr, "Чтение файла %s: процент чтения: %.2f%%, объем прочитанных данных: %d МБайт\n", rp.name, percentage, rp.rtotal/1024/1024)
 			rp.progress = percentage
 		}
 	} else if n == 0 {
 
-		fmt.Printf("Чтение файла %s завершено\n", rp.name)
+		fmt.Fprintf(os.Stderr, "Чтение файла %s завершено\n", rp.name)
 	}
 
 	return n, err
@@ -68,7 +68,7 @@ func (rp *Piper) Write(p []byte) (int, error) {
 
 	if n > 0 {
 		rp.wtotal += int64(n)
-		fmt.Printf("Чтение файла %s: объем прочитанных данных: %d МБайт\n", rp.name, rp.rtotal/1024/1024)
+		fmt.Fprintf(os.Stderr, "Чтение файла %s: объем прочитанных данных: %d МБайт\n", rp.name, rp.rtotal/1024/1024)
 	}
 
 	return n, err
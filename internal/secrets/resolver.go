@@ -0,0 +1,53 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Resolver подставляет значения ссылок env:NAME, file:/path и vault:name
+type Resolver struct {
+	vault *Vault
+}
+
+func NewResolver(vault *Vault) *Resolver {
+	return &Resolver{vault: vault}
+}
+
+func (r *Resolver) Resolve(ref string) (string, error) {
+	kind, name, _ := strings.Cut(ref, ":")
+
+	switch kind {
+	case "env":
+		value, ok := os.LookupEnv(name)
+
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+
+		return value, nil
+	case "file":
+		data, err := os.ReadFile(name)
+
+		if err != nil {
+			return "", fmt.Errorf("unable to read secret file %s: %v", name, err)
+		}
+
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case "vault":
+		if r.vault == nil {
+			return "", fmt.Errorf("secret %s refers to vault, but vault is not configured", name)
+		}
+
+		value, ok := r.vault.Get(name)
+
+		if !ok {
+			return "", fmt.Errorf("secret %s not found in vault", name)
+		}
+
+		return value, nil
+	default:
+		return ref, nil
+	}
+}
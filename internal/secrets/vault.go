@@ -0,0 +1,165 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+	"os"
+	"path/filepath"
+	"sort"
+	"yd_backup/internal/repo"
+)
+
+// Vault - локальный зашифрованный файл с секретами. Ключ AES-256-GCM
+// выводится из мастер-ключа через scrypt с солью, хранящейся в файле
+type Vault struct {
+	path    string
+	key     string
+	secrets map[string]string
+}
+
+type vaultFile struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+func NewVault(path string, key string) *Vault {
+	return &Vault{
+		path:    path,
+		key:     key,
+		secrets: make(map[string]string),
+	}
+}
+
+// Open читает и расшифровывает файл. Отсутствие файла не считается
+// ошибкой: хранилище создается при первом Set
+func (v *Vault) Open() error {
+	if v.key == "" {
+		return fmt.Errorf("vault master key is empty")
+	}
+
+	data, err := os.ReadFile(v.path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to read vault %s: %v", v.path, err)
+	}
+
+	var file vaultFile
+
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("unable to parse vault %s: %v", v.path, err)
+	}
+
+	gcm, err := v.cipher(file.Salt)
+
+	if err != nil {
+		return err
+	}
+
+	plain, err := gcm.Open(nil, file.Nonce, file.Data, nil)
+
+	if err != nil {
+		return fmt.Errorf("unable to decrypt vault %s: wrong master key or corrupted file", v.path)
+	}
+
+	return json.Unmarshal(plain, &v.secrets)
+}
+
+func (v *Vault) Get(name string) (string, bool) {
+	value, ok := v.secrets[name]
+
+	return value, ok
+}
+
+func (v *Vault) Names() []string {
+	var names []string
+
+	for name := range v.secrets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Set сохраняет секрет и перешифровывает файл с новой солью
+func (v *Vault) Set(name string, value string) error {
+	v.secrets[name] = value
+
+	return v.save()
+}
+
+func (v *Vault) save() error {
+	plain, err := json.Marshal(v.secrets)
+
+	if err != nil {
+		return err
+	}
+
+	file := vaultFile{
+		Salt: make([]byte, 16),
+	}
+
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+
+	gcm, err := v.cipher(file.Salt)
+
+	if err != nil {
+		return err
+	}
+
+	file.Nonce = make([]byte, gcm.NonceSize())
+
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+
+	file.Data = gcm.Seal(nil, file.Nonce, plain, nil)
+
+	data, err := json.Marshal(file)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(v.path), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create vault dir: %v", err)
+	}
+
+	// Временный файл создается с правами 0600, так что секреты
+	// не становятся видны другим пользователям даже на время записи
+	if err := repo.AtomicWrite(v.path, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("unable to write vault %s: %v", v.path, err)
+	}
+
+	return nil
+}
+
+func (v *Vault) cipher(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(v.key), salt, 1<<15, 8, 1, 32)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,317 @@
+package usecase
+
+import (
+	"compress/flate"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"yd_backup/internal/models"
+)
+
+// Сжатие оценивается по выборочным блокам, чтобы не сжимать всю копию
+const (
+	sampleBlocks = 16
+	sampleSize   = 64 * 1024
+)
+
+// Меньше нормальных копий в истории недостаточно для базовой линии
+const minHistory = 3
+
+// byteHistogram считает частоты байтов записанного содержимого
+type byteHistogram struct {
+	counts [256]int64
+	total  int64
+}
+
+func (h *byteHistogram) Write(p []byte) (int, error) {
+	for _, c := range p {
+		h.counts[c]++
+	}
+
+	h.total += int64(len(p))
+
+	return len(p), nil
+}
+
+// entropy - энтропия Шеннона в битах на байт: около 8 у сжатых и
+// зашифрованных данных, заметно меньше у файлов баз данных
+func (h *byteHistogram) entropy() float64 {
+	if h.total == 0 {
+		return 0
+	}
+
+	var result float64
+
+	for _, count := range h.counts {
+		if count == 0 {
+			continue
+		}
+
+		p := float64(count) / float64(h.total)
+		result -= p * math.Log2(p)
+	}
+
+	return result
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// compressionRatio сжимает равномерно расположенные блоки файла и
+// возвращает отношение сжатого размера к исходному, ноль при ошибке
+func compressionRatio(filePath string) float64 {
+	file, err := os.Open(filePath)
+
+	if err != nil {
+		return 0
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+
+	if err != nil || info.Size() == 0 {
+		return 0
+	}
+
+	blocks := int64(sampleBlocks)
+
+	if available := (info.Size() + sampleSize - 1) / sampleSize; available < blocks {
+		blocks = available
+	}
+
+	var compressed countingWriter
+
+	writer, err := flate.NewWriter(&compressed, flate.BestSpeed)
+
+	if err != nil {
+		return 0
+	}
+
+	var sampled int64
+
+	for i := int64(0); i < blocks; i++ {
+		var offset int64
+
+		if blocks > 1 && info.Size() > sampleSize {
+			offset = i * (info.Size() - sampleSize) / (blocks - 1)
+		}
+
+		n, err := io.Copy(writer, io.NewSectionReader(file, offset, sampleSize))
+
+		if err != nil {
+			return 0
+		}
+
+		sampled += n
+	}
+
+	if err := writer.Close(); err != nil || sampled == 0 {
+		return 0
+	}
+
+	return float64(compressed.n) / float64(sampled)
+}
+
+// detectAnomalies сравнивает копию с медианой последних нормальных копий
+// задания и отмечает ее подозрительной при отклонении сверх порогов
+func (b *BackupService) detectAnomalies(entry *models.CatalogEntry) {
+	setting := b.setting.Anomaly
+
+	if setting.Disabled {
+		return
+	}
+
+	history := b.history(entry.Job, setting.History)
+
+	if len(history) < minHistory {
+		return
+	}
+
+	var sizes, ratios, entropies []float64
+
+	for _, previous := range history {
+		sizes = append(sizes, float64(previous.Size))
+
+		if previous.Ratio > 0 {
+			ratios = append(ratios, previous.Ratio)
+		}
+
+		if previous.Entropy > 0 {
+			entropies = append(entropies, previous.Entropy)
+		}
+	}
+
+	if baseline := median(sizes); baseline > 0 {
+		if deviation := (float64(entry.Size) - baseline) / baseline; math.Abs(deviation) > setting.Size {
+			entry.Anomalies = append(entry.Anomalies, fmt.Sprintf("size %d differs by %+.0f%% from median %.0f",
+				entry.Size, deviation*100, baseline))
+		}
+	}
+
+	if baseline := median(ratios); baseline > 0 && entry.Ratio > 0 && len(ratios) >= minHistory {
+		if deviation := (entry.Ratio - baseline) / baseline; math.Abs(deviation) > setting.Ratio {
+			entry.Anomalies = append(entry.Anomalies, fmt.Sprintf("compression ratio %.2f differs by %+.0f%% from median %.2f",
+				entry.Ratio, deviation*100, baseline))
+		}
+	}
+
+	if baseline := median(entropies); baseline > 0 && entry.Entropy > 0 && len(entropies) >= minHistory {
+		if deviation := entry.Entropy - baseline; math.Abs(deviation) > setting.Entropy {
+			entry.Anomalies = append(entry.Anomalies, fmt.Sprintf("entropy %.2f bits differs by %+.2f from median %.2f",
+				entry.Entropy, deviation, baseline))
+		}
+	}
+
+	if len(entry.Anomalies) == 0 {
+		return
+	}
+
+	entry.Suspicious = true
+
+	b.logger.With(zap.String("Job", entry.Job)).With(zap.String("ID", entry.ID)).
+		With(zap.Strings("anomalies", entry.Anomalies)).Warn("Backup is suspicious")
+}
+
+// history возвращает до limit последних нормальных копий задания, от новых
+// к старым
+func (b *BackupService) history(job string, limit int) []models.CatalogEntry {
+	entries, err := b.catalog.Entries()
+
+	if err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to read catalog")
+		return nil
+	}
+
+	var result []models.CatalogEntry
+
+	for _, entry := range entries {
+		if entry.Job == job && !entry.Suspicious && entry.Size > 0 {
+			result = append(result, entry)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Created.After(result[j].Created)
+	})
+
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result
+}
+
+// IsProtected сообщает, что копию с путем path нельзя удалять при очистке:
+// она закреплена или это одна из последних нормальных копий задания, у
+// которого есть подозрительные копии. Сравниваются полные пути, так как по
+// шаблону пути у разных копий могут быть одинаковые имена файлов
+func (b *BackupService) IsProtected(filePath string) bool {
+	b.protectedMu.Lock()
+	defer b.protectedMu.Unlock()
+
+	return b.protected[models.CleanPath(filePath)]
+}
+
+// protect собирает пути локальных и удаленных файлов копий, которые
+// очистка должна сохранить: закрепленных копий и последних нормальных копий
+// заданий, пока их подозрительные копии хранятся хотя бы где-то
+func (b *BackupService) protect() {
+	protected := make(map[string]bool)
+
+	defer func() {
+		b.protectedMu.Lock()
+		b.protected = protected
+		b.protectedMu.Unlock()
+	}()
+
+	if b.catalog == nil {
+		return
+	}
+
+	entries, err := b.catalog.Entries()
+
+	if err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to read catalog")
+		return
+	}
+
+	suspicious := make(map[string]bool)
+
+	for _, entry := range entries {
+		if entry.Pinned {
+			protectEntry(protected, entry)
+		}
+
+		if entry.Suspicious && isStored(entry) {
+			suspicious[entry.Job] = true
+		}
+	}
+
+	if b.setting.Anomaly.Disabled {
+		return
+	}
+
+	for job := range suspicious {
+		for _, entry := range b.history(job, b.setting.Anomaly.Keep) {
+			protectEntry(protected, entry)
+
+			b.logger.With(zap.String("Job", job)).With(zap.String("ID", entry.ID)).
+				Debug("Backup is kept because the job has suspicious backups")
+		}
+	}
+}
+
+// protectEntry добавляет в protected пути локального и удаленных файлов копии
+func protectEntry(protected map[string]bool, entry models.CatalogEntry) {
+	if entry.LocalPath != "" {
+		protected[models.CleanPath(entry.LocalPath)] = true
+	}
+
+	for _, catalogCopy := range entry.Copies {
+		if catalogCopy.RemotePath != "" {
+			protected[models.CleanPath(catalogCopy.RemotePath)] = true
+		}
+	}
+}
+
+// isStored сообщает, что копия еще есть локально или хотя бы в одном назначении
+func isStored(entry models.CatalogEntry) bool {
+	if entry.LocalPath != "" {
+		return true
+	}
+
+	for _, catalogCopy := range entry.Copies {
+		if catalogCopy.Status != models.CopyRemoved {
+			return true
+		}
+	}
+
+	return false
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	middle := len(sorted) / 2
+
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+
+	return sorted[middle]
+}
@@ -1,129 +1,540 @@
 package usecase
 
 import (
+	"errors"
 	"fmt"
 	"go.uber.org/zap"
+	"os"
+	"sort"
 	"sync"
+	"time"
 	"yd_backup/internal/models"
 )
 
 type LocalBackup interface {
 	CreateBackup(file models.Files) (string, error)
 	EraseBackup() ([]string, error)
+	ListBackup() ([]models.BackupFile, error)
 }
 
 type RemoteBackup interface {
-	CreateFolder(dir string) error
-	UploadBackup(backupPath string) error
+	CreateFolder() error
+	UploadBackup(backupPath string, name string) error
 	RemoveBackup() ([]string, error)
+	ListBackup() ([]models.BackupFile, error)
+	DownloadBackup(name string, targetPath string) error
+	RemotePath(name string) string
+	UploadCatalog(data []byte) error
+	DownloadCatalog() ([]byte, error)
 }
 
-type Result struct {
-	sync.Mutex
-	success int
+// Status - итог выполнения команды для кода завершения процесса
+type Status int
+
+const (
+	StatusSuccess Status = iota
+	StatusPartial
+	StatusFailed
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusSuccess:
+		return "success"
+	case StatusPartial:
+		return "partial"
+	default:
+		return "failed"
+	}
+}
+
+func newStatus(success int, total int) Status {
+	switch {
+	case success == total:
+		return StatusSuccess
+	case success == 0:
+		return StatusFailed
+	default:
+		return StatusPartial
+	}
+}
+
+// Destination - назначение с его настройками и реализацией хранилища
+type Destination struct {
+	models.Destination
+	Remote RemoteBackup
 }
 
-func (r *Result) getSuccess() int {
-	r.Lock()
-	defer r.Unlock()
-	return r.success
+// DestinationResult - результат загрузки копии в одно назначение
+type DestinationResult struct {
+	Name     string        `json:"name"`
+	Required bool          `json:"required"`
+	Duration time.Duration `json:"duration"`
+	Bytes    int64         `json:"bytes"`
+	Error    string        `json:"error,omitempty"`
+	Err      error         `json:"-"`
 }
 
-func (r *Result) setSuccess() {
-	r.Lock()
-	defer r.Unlock()
-	r.success++
+// JobOutcome - итог одного задания: время локального копирования и
+// загрузок, размер копии и результаты по назначениям
+type JobOutcome struct {
+	Job          string              `json:"job"`
+	RunID        string              `json:"run_id"`
+	Path         string              `json:"path"`
+	Priority     int                 `json:"priority"`
+	Started      time.Time           `json:"started"`
+	Finished     time.Time           `json:"finished"`
+	CopyDuration time.Duration       `json:"copy_duration"`
+	Bytes        int64               `json:"bytes"`
+	Uploaded     int64               `json:"uploaded"`
+	Destinations []DestinationResult `json:"destinations"`
+	Anomalies    []string            `json:"anomalies,omitempty"`
+	Error        string              `json:"error,omitempty"`
+	Err          error               `json:"-"`
+}
+
+func (o *JobOutcome) fail(err error) {
+	o.Err = err
+	o.Error = err.Error()
+}
+
+// Duration - полное время задания, включая ожидание свободных слотов
+func (o *JobOutcome) Duration() time.Duration {
+	return o.Finished.Sub(o.Started)
+}
+
+// Summary - число успешных и неуспешных загрузок в назначение
+type Summary struct {
+	Success int
+	Failed  int
 }
 
 type BackupService struct {
-	setting models.Setting
-	remote  RemoteBackup
-	local   LocalBackup
-	logger  *zap.Logger
+	setting      models.Setting
+	destinations []Destination
+	local        LocalBackup
+	logger       *zap.Logger
+	locker       Locker
+	leases       *leaseManager
+	copySlots    chan struct{}
+	uploadSlots  chan struct{}
+	queue        UploadQueue
+	retry        sync.Mutex
+	catalog      Catalog
+	notifiers    []Notifier
+	state        StateStore
+	metrics      Metrics
+	pinger       Pinger
+	credentials  CredentialsChecker
+	protected    map[string]bool
+	protectedMu  sync.Mutex
+	version      string
 }
 
-func NewBackupService(setting models.Setting, remote RemoteBackup, local LocalBackup, logger *zap.Logger) *BackupService {
+func NewBackupService(setting models.Setting, destinations []Destination, local LocalBackup, logger *zap.Logger) *BackupService {
 	return &BackupService{
-		setting: setting,
-		remote:  remote,
-		local:   local,
-		logger:  logger,
+		setting:      setting,
+		destinations: destinations,
+		local:        local,
+		logger:       logger,
+		copySlots:    make(chan struct{}, max(setting.Backup.CopyWorkers, 1)),
+		uploadSlots:  make(chan struct{}, max(setting.Backup.UploadWorkers, 1)),
 	}
 }
 
-func (b *BackupService) BackupAll() {
-
-	err := b.remote.CreateFolder(b.setting.Yandex.Dir)
+// BackupAll выполняет задания с указанными именами, а без имен - все задания.
+// Задания начинают локальное копирование в порядке убывания приоритета, при
+// равном приоритете - в порядке конфигурации. Число одновременных копирований
+// и загрузок ограничено общими для сервиса слотами
+func (b *BackupService) BackupAll(names []string) ([]JobOutcome, Status, error) {
+	files, err := b.selectFiles(names)
 
 	if err != nil {
-		b.logger.With(zap.Error(err)).Error("Unable to create remote folder")
-		return
+		return nil, StatusFailed, err
 	}
 
-	wg := &sync.WaitGroup{}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Priority > files[j].Priority
+	})
 
-	result := &Result{
-		success: 0,
+	started := time.Now()
+
+	for _, destination := range b.destinations {
+		if err := destination.Remote.CreateFolder(); err != nil {
+			b.logger.With(zap.String("Destination", destination.Name)).With(zap.Error(err)).
+				Error("Unable to create remote folder")
+		}
+
+		// Аренда держится на все время запуска, задания лишь увеличивают счетчик.
+		// Без аренды загрузки в назначение завершатся ошибкой в upload, как и
+		// при захвате аренды отдельным заданием
+		if err := b.leases.acquire(destination); err != nil {
+			b.logger.With(zap.String("Destination", destination.Name)).With(zap.Error(err)).
+				Error("Unable to lease remote folder")
+			continue
+		}
+
+		defer b.leases.release(destination)
 	}
 
-	for _, path := range b.setting.Files {
+	b.checkQuota(files)
+
+	// Сначала догружаются копии прошлых запусков
+	b.RetryUploads()
+
+	outcomes := make([]JobOutcome, len(files))
+
+	wg := &sync.WaitGroup{}
+
+	for i, job := range files {
+		outcomes[i] = JobOutcome{
+			Job:      job.Name,
+			Path:     job.Path,
+			Priority: job.Priority,
+			Started:  time.Now(),
+		}
+
+		// Слот копирования занимается здесь, чтобы задания стартовали по приоритету
+		b.copySlots <- struct{}{}
+
 		wg.Add(1)
 
-		currentPath := path
+		go func(outcome *JobOutcome, job models.Files) {
+			defer wg.Done()
+
+			b.run(job, outcome)
 
-		go func() {
-			if err := b.Backup(currentPath); err != nil {
-				b.logger.With(zap.String("Path", currentPath.Path)).With(zap.Error(err)).Error("Backup failed")
+			if outcome.Err != nil {
+				b.logger.With(zap.String("Path", job.Path)).With(zap.Error(outcome.Err)).Error("Backup failed")
 			} else {
-				b.logger.With(zap.String("Path", currentPath.Path)).Info("Backup success")
-				result.setSuccess()
+				b.logger.With(zap.String("Path", job.Path)).
+					With(zap.Duration("copy_duration", outcome.CopyDuration)).
+					With(zap.Duration("duration", outcome.Duration())).
+					With(zap.Int64("bytes", outcome.Bytes)).
+					Info("Backup success")
 			}
-
-			wg.Done()
-		}()
+		}(&outcomes[i], job)
 	}
 
 	wg.Wait()
 
-	b.logger.With(zap.String("progress", fmt.Sprintf("%d/%d", result.getSuccess(), len(b.setting.Files)))).
+	success := 0
+
+	for _, outcome := range outcomes {
+		if outcome.Err == nil {
+			success++
+		}
+	}
+
+	for _, destination := range b.destinations {
+		summary := destinationSummary(outcomes, destination.Name)
+
+		b.logger.With(zap.String("Destination", destination.Name)).
+			With(zap.Bool("Required", destination.Required)).
+			With(zap.Int("success", summary.Success)).
+			With(zap.Int("failed", summary.Failed)).
+			Info("Destination summary")
+	}
+
+	b.logger.With(zap.String("progress", fmt.Sprintf("%d/%d", success, len(files)))).
 		Info("Backup complete")
 
+	b.publishCatalog()
+
+	status := newStatus(success, len(files))
+
+	// Подозрительная копия создана, но требует внимания
+	for _, outcome := range outcomes {
+		if len(outcome.Anomalies) > 0 && status == StatusSuccess {
+			status = StatusPartial
+		}
+	}
+
+	b.notifyBackup(outcomes, started, status)
+
+	b.observeJobs(outcomes)
+	b.RefreshMetrics()
+
+	return outcomes, status, nil
+}
+
+func destinationSummary(outcomes []JobOutcome, name string) Summary {
+	var summary Summary
+
+	for _, outcome := range outcomes {
+		for _, result := range outcome.Destinations {
+			if result.Name != name {
+				continue
+			}
+
+			if result.Err != nil {
+				summary.Failed++
+			} else {
+				summary.Success++
+			}
+		}
+	}
+
+	return summary
+}
+
+func (b *BackupService) selectFiles(names []string) ([]models.Files, error) {
+	if len(names) == 0 {
+		return append([]models.Files(nil), b.setting.Files...), nil
+	}
+
+	var result []models.Files
+
+	for _, name := range names {
+		found := false
+
+		for _, files := range b.setting.Files {
+			if files.Name == name {
+				result = append(result, files)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown job %s", name)
+		}
+	}
+
+	return result, nil
+}
+
+// Backup выполняет одно задание вне BackupAll
+func (b *BackupService) Backup(files models.Files) JobOutcome {
+	outcome := JobOutcome{
+		Job:      files.Name,
+		Path:     files.Path,
+		Priority: files.Priority,
+		Started:  time.Now(),
+	}
+
+	b.copySlots <- struct{}{}
+
+	b.run(files, &outcome)
+
+	return outcome
 }
 
-func (b *BackupService) Backup(files models.Files) error {
+// backup создает одну локальную копию и раздает ее во все назначения задания.
+// Вызывающий занимает слот копирования, backup освобождает его после
+// копирования. Задание считается неуспешным, если не удалась загрузка хотя
+// бы в одно обязательное назначение или копия не попала ни в одно назначение;
+// остальные ошибки необязательных назначений только логируются
+func (b *BackupService) backup(files models.Files, outcome *JobOutcome) {
+	copying := true
+
+	releaseCopy := func() {
+		if copying {
+			copying = false
+			<-b.copySlots
+		}
+	}
+
+	defer releaseCopy()
+
+	destinations, err := b.jobDestinations(files)
+	if err != nil {
+		outcome.fail(err)
+		return
+	}
+
+	unlock, err := b.lock("job-" + files.Name)
+	if err != nil {
+		outcome.fail(fmt.Errorf("job is already running: %v", err))
+		return
+	}
+	defer unlock()
+
+	copyStarted := time.Now()
+
 	//TODO: Создать локальную копию
 	backupPath, err := b.local.CreateBackup(files)
+
+	outcome.CopyDuration = time.Since(copyStarted)
+
+	releaseCopy()
+
 	if err != nil {
-		return fmt.Errorf("unable to create local backup: %v", err)
+		outcome.fail(fmt.Errorf("unable to create local backup: %v", err))
+		return
 	}
-	//TODO: Создать удаленную копию
 
-	err = b.remote.UploadBackup(backupPath)
+	if info, err := os.Stat(backupPath); err == nil {
+		outcome.Bytes = info.Size()
+	}
 
-	if err != nil {
-		return fmt.Errorf("unable to upload backup to remote disk: %v", err)
+	b.record(files, backupPath, outcome, destinations)
+
+	results := make([]DestinationResult, len(destinations))
+
+	wg := &sync.WaitGroup{}
+
+	for i, destination := range destinations {
+		wg.Add(1)
+
+		go func(result *DestinationResult, destination Destination) {
+			defer wg.Done()
+
+			result.Name = destination.Name
+			result.Required = destination.Required
+
+			upload := b.enqueue(files, backupPath, destination.Name)
+
+			result.Duration, result.Err = b.upload(destination, backupPath, b.remoteName(files, destination, backupPath))
+
+			b.recordUpload(backupPath, destination.Name, result.Err)
+			b.confirm(upload, result.Err)
+
+			if result.Err == nil {
+				result.Bytes = outcome.Bytes
+			}
+		}(&results[i], destination)
+	}
+
+	wg.Wait()
+
+	outcome.Destinations = results
+
+	var failed []string
+
+	uploaded := 0
+
+	for i, result := range results {
+		if result.Err == nil {
+			outcome.Uploaded += result.Bytes
+			uploaded++
+			continue
+		}
+
+		results[i].Error = result.Err.Error()
+
+		logger := b.logger.With(zap.String("Path", files.Path)).
+			With(zap.String("Destination", result.Name)).
+			With(zap.Error(result.Err))
+
+		if result.Required {
+			logger.Error("Upload failed")
+			failed = append(failed, result.Name)
+		} else {
+			logger.Warn("Upload to optional destination failed")
+		}
 	}
 
-	return nil
+	if len(failed) > 0 {
+		outcome.fail(fmt.Errorf("unable to upload backup to required destinations %v", failed))
+		return
+	}
+
+	if len(results) > 0 && uploaded == 0 {
+		outcome.fail(errors.New("unable to upload backup to any destination"))
+	}
 }
 
-func (b *BackupService) EraseBackup() {
+// jobDestinations возвращает назначения, на которые ссылается задание.
+// Задание без списка назначений отправляется во все назначения своего
+// аккаунта, а если аккаунт не указан - во все назначения
+func (b *BackupService) jobDestinations(files models.Files) ([]Destination, error) {
+	var result []Destination
+
+	if len(files.Destinations) == 0 && files.Account != "" {
+		for _, destination := range b.destinations {
+			if destination.Type == models.DestinationYandex && destination.Account == files.Account {
+				result = append(result, destination)
+			}
+		}
+
+		if len(result) == 0 {
+			return nil, fmt.Errorf("no destinations for account %s", files.Account)
+		}
+
+		return result, nil
+	}
+
+	if len(files.Destinations) == 0 {
+		return b.destinations, nil
+	}
+
+	for _, name := range files.Destinations {
+		found := false
+
+		for _, destination := range b.destinations {
+			if destination.Name == name {
+				result = append(result, destination)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown destination %s", name)
+		}
+	}
+
+	return result, nil
+}
+
+func (b *BackupService) EraseBackup() Status {
+	unlock, err := b.lock("prune")
+	if err != nil {
+		b.logger.With(zap.Error(err)).Info("Prune is already running in another process, skipped")
+		return StatusSuccess
+	}
+	defer unlock()
+
+	b.protect()
+
 	paths, err := b.local.EraseBackup()
 	if err != nil {
 		b.logger.With(zap.Error(err)).Error("unable to erase local backup: %v")
-		return
+		return StatusFailed
 	}
 
 	b.logger.With(zap.Strings("paths", paths)).With(zap.Int("count", len(paths))).Info("Local backup erased")
 
-	paths, err = b.remote.RemoveBackup()
+	b.recordRemoved("", paths)
+	b.observeRetention(MetricLocalDestination, paths)
 
-	if err != nil {
-		b.logger.With(zap.Error(err)).Error("unable to erase remote backup: %v")
-		return
+	success := 0
+
+	for _, destination := range b.destinations {
+		if err := b.leases.acquire(destination); err != nil {
+			b.logger.With(zap.String("Destination", destination.Name)).With(zap.Error(err)).
+				Error("unable to erase remote backup: folder is leased")
+			continue
+		}
+
+		paths, err = destination.Remote.RemoveBackup()
+
+		b.leases.release(destination)
+
+		if err != nil {
+			b.logger.With(zap.String("Destination", destination.Name)).With(zap.Error(err)).
+				Error("unable to erase remote backup: %v")
+			continue
+		}
+
+		success++
+
+		b.recordRemoved(destination.Name, paths)
+		b.observeRetention(destination.Name, paths)
+
+		b.logger.With(zap.String("Destination", destination.Name)).
+			With(zap.Strings("paths", paths)).With(zap.Int("count", len(paths))).Info("Remote backup erased")
+
+		if _, ok := destination.Remote.(Trash); ok && destination.Delete == models.DeleteTrash &&
+			destination.TrashGrace.Duration > 0 {
+			b.purgeTrash(destination, destination.TrashGrace.Duration)
+		}
 	}
 
-	b.logger.With(zap.Strings("paths", paths)).With(zap.Int("count", len(paths))).Info("Remote backup erased")
+	b.publishCatalog()
+
+	b.RefreshMetrics()
 
-	return
+	return newStatus(success, len(b.destinations))
 }
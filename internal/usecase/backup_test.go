@@ -0,0 +1,172 @@
+package usecase
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"yd_backup/internal/models"
+)
+
+// fakeLocal создает копии заданий в директории dir и считает очистки
+type fakeLocal struct {
+	dir    string
+	mu     sync.Mutex
+	erased int
+}
+
+func (l *fakeLocal) CreateBackup(files models.Files) (string, error) {
+	path := filepath.Join(l.dir, files.Name+"_20260101_120000.zip")
+
+	return path, os.WriteFile(path, []byte("backup of "+files.Name), 0600)
+}
+
+func (l *fakeLocal) EraseBackup() ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.erased++
+
+	return nil, nil
+}
+
+func (l *fakeLocal) erases() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.erased
+}
+
+func (l *fakeLocal) ListBackup() ([]models.BackupFile, error) {
+	files, err := os.ReadDir(l.dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var result []models.BackupFile
+
+	for _, file := range files {
+		info, err := file.Info()
+
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, models.BackupFile{
+			Name:    file.Name(),
+			Path:    filepath.Join(l.dir, file.Name()),
+			Size:    info.Size(),
+			Created: info.ModTime(),
+		})
+	}
+
+	return result, nil
+}
+
+// fakeRemote - назначение в памяти с файлами files. Загрузка завершается
+// ошибкой err, а перед ней вызывается onUpload
+type fakeRemote struct {
+	mu       sync.Mutex
+	err      error
+	onUpload func(backupPath string)
+	uploaded []string
+	files    []models.BackupFile
+}
+
+func (r *fakeRemote) CreateFolder() error {
+	return nil
+}
+
+func (r *fakeRemote) UploadBackup(backupPath string, name string) error {
+	if r.onUpload != nil {
+		r.onUpload(backupPath)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+
+	r.uploaded = append(r.uploaded, name)
+
+	return nil
+}
+
+func (r *fakeRemote) RemoveBackup() ([]string, error) {
+	return nil, nil
+}
+
+func (r *fakeRemote) ListBackup() ([]models.BackupFile, error) {
+	return r.files, nil
+}
+
+func (r *fakeRemote) DownloadBackup(name string, targetPath string) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeRemote) RemotePath(name string) string {
+	return name
+}
+
+func (r *fakeRemote) UploadCatalog(data []byte) error {
+	return nil
+}
+
+func (r *fakeRemote) DownloadCatalog() ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r *fakeRemote) uploads() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.uploaded...)
+}
+
+func newTestService(t *testing.T, destinations ...Destination) *BackupService {
+	t.Helper()
+
+	setting := models.Setting{
+		Files: []models.Files{{Name: "Trade", Path: filepath.Join(t.TempDir(), "trade.db")}},
+	}
+
+	return NewBackupService(setting, destinations, &fakeLocal{dir: t.TempDir()}, zap.NewNop())
+}
+
+func testDestination(name string, remote *fakeRemote) Destination {
+	return Destination{Destination: models.Destination{Name: name, Type: models.DestinationNas}, Remote: remote}
+}
+
+func TestBackupAllUploadsFailed(t *testing.T) {
+	disk := &fakeRemote{err: errors.New("no route to host")}
+	nas := &fakeRemote{err: errors.New("access denied")}
+
+	// Оба назначения необязательные, но копия не попала ни в одно из них
+	service := newTestService(t, testDestination("disk", disk), testDestination("nas", nas))
+
+	outcomes, status, err := service.BackupAll(nil)
+
+	if err != nil {
+		t.Fatalf("BackupAll: %v", err)
+	}
+
+	if status != StatusFailed {
+		t.Errorf("status = %v, want failed", status)
+	}
+
+	if len(outcomes) != 1 || outcomes[0].Err == nil {
+		t.Fatalf("outcomes = %+v, want failed job", outcomes)
+	}
+
+	// Ошибка одного необязательного назначения задание не проваливает
+	nas.err = nil
+
+	if _, status, _ := service.BackupAll(nil); status != StatusSuccess {
+		t.Errorf("status = %v with one destination uploaded, want success", status)
+	}
+}
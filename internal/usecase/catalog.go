@@ -0,0 +1,252 @@
+package usecase
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+	"yd_backup/internal/models"
+)
+
+// Catalog - каталог всех созданных копий
+type Catalog interface {
+	Put(entry models.CatalogEntry) error
+	Update(id string, update func(entry *models.CatalogEntry)) error
+	Entries() ([]models.CatalogEntry, error)
+	Replace(entries []models.CatalogEntry) error
+	Data() ([]byte, error)
+}
+
+func (b *BackupService) SetCatalog(catalog Catalog) {
+	b.catalog = catalog
+}
+
+// record добавляет в каталог новую локальную копию с ожидающими загрузки
+// копиями во всех назначениях задания
+func (b *BackupService) record(files models.Files, backupPath string, outcome *JobOutcome, destinations []Destination) {
+	if b.catalog == nil {
+		return
+	}
+
+	var histogram byteHistogram
+
+	md5Sum, sha256Sum, err := fileHashes(backupPath, &histogram)
+
+	if err != nil {
+		b.logger.With(zap.String("Path", backupPath)).With(zap.Error(err)).Error("unable to hash backup")
+	}
+
+	entry := models.CatalogEntry{
+		ID:          filepath.Base(backupPath),
+		Job:         files.Name,
+		Source:      files.Path,
+		LocalPath:   backupPath,
+		Started:     outcome.Started,
+		Created:     time.Now(),
+		Size:        outcome.Bytes,
+		Md5:         md5Sum,
+		Sha256:      sha256Sum,
+		Compression: models.CompressionNone,
+		Encryption:  models.EncryptionNone,
+		Entropy:     histogram.entropy(),
+		Ratio:       compressionRatio(backupPath),
+	}
+
+	for _, destination := range destinations {
+		name := b.remoteName(files, destination, backupPath)
+
+		entry.Copies = append(entry.Copies, models.CatalogCopy{
+			Destination: destination.Name,
+			Name:        name,
+			RemotePath:  destination.Remote.RemotePath(name),
+			Status:      models.CopyPending,
+		})
+	}
+
+	b.detectAnomalies(&entry)
+
+	outcome.Anomalies = entry.Anomalies
+
+	if err := b.catalog.Put(entry); err != nil {
+		b.logger.With(zap.String("Path", backupPath)).With(zap.Error(err)).Error("unable to update catalog")
+	}
+}
+
+// recordUpload отмечает в каталоге результат загрузки копии в назначение
+func (b *BackupService) recordUpload(backupPath string, destination string, err error) {
+	b.updateCatalog(filepath.Base(backupPath), func(entry *models.CatalogEntry) {
+		catalogCopy := entry.Copy(destination)
+
+		if catalogCopy == nil {
+			return
+		}
+
+		if err != nil {
+			catalogCopy.Status = models.CopyPending
+			catalogCopy.Error = err.Error()
+			return
+		}
+
+		catalogCopy.Status = models.CopyUploaded
+		catalogCopy.Uploaded = time.Now()
+		catalogCopy.Error = ""
+	})
+}
+
+// recordRemoved отмечает в каталоге удаленные при очистке копии. Для
+// локальной директории destination пуст
+func (b *BackupService) recordRemoved(destination string, paths []string) {
+	if b.catalog == nil || len(paths) == 0 {
+		return
+	}
+
+	removed := make(map[string]bool)
+
+	for _, removedPath := range paths {
+		removed[path.Base(filepath.ToSlash(removedPath))] = true
+	}
+
+	isRemoved := func(catalogCopy *models.CatalogCopy) bool {
+		for _, removedPath := range paths {
+			if models.SamePath(removedPath, catalogCopy.RemotePath) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	entries, err := b.catalog.Entries()
+
+	if err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to read catalog")
+		return
+	}
+
+	for _, entry := range entries {
+		if destination == "" {
+			if removed[entry.ID] {
+				b.updateCatalog(entry.ID, func(entry *models.CatalogEntry) {
+					entry.LocalPath = ""
+				})
+			}
+
+			continue
+		}
+
+		catalogCopy := entry.Copy(destination)
+
+		if catalogCopy != nil && isRemoved(catalogCopy) {
+			b.updateCatalog(entry.ID, func(entry *models.CatalogEntry) {
+				entry.Copy(destination).Status = models.CopyRemoved
+			})
+		}
+	}
+}
+
+func (b *BackupService) updateCatalog(id string, update func(entry *models.CatalogEntry)) {
+	if b.catalog == nil {
+		return
+	}
+
+	if err := b.catalog.Update(id, update); err != nil {
+		b.logger.With(zap.String("ID", id)).With(zap.Error(err)).Error("unable to update catalog")
+	}
+}
+
+// findEntry ищет запись каталога по ID, по пути копии относительно папки
+// назначения или по полному пути в назначении. Шаблон пути может
+// переиспользовать путь, поэтому из нескольких записей выбирается последняя
+func (b *BackupService) findEntry(destination string, name string) (models.CatalogEntry, bool) {
+	if b.catalog == nil {
+		return models.CatalogEntry{}, false
+	}
+
+	entries, err := b.catalog.Entries()
+
+	if err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to read catalog")
+		return models.CatalogEntry{}, false
+	}
+
+	var (
+		result models.CatalogEntry
+		found  bool
+	)
+
+	for _, entry := range entries {
+		catalogCopy := entry.Copy(destination)
+
+		if catalogCopy == nil {
+			continue
+		}
+
+		if entry.ID == name || isCopyPath(*catalogCopy, name) {
+			result, found = entry, true
+		}
+	}
+
+	return result, found
+}
+
+// isCopyPath сообщает, что name - путь копии относительно папки назначения
+// или ее полный путь в назначении
+func isCopyPath(catalogCopy models.CatalogCopy, name string) bool {
+	return catalogCopy.RemoteName() == filepath.ToSlash(name) || models.SamePath(catalogCopy.RemotePath, name)
+}
+
+// publishCatalog загружает копию каталога в каждое назначение, чтобы
+// каталог можно было восстановить после потери машины
+func (b *BackupService) publishCatalog() {
+	if b.catalog == nil {
+		return
+	}
+
+	data, err := b.catalog.Data()
+
+	if err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to read catalog")
+		return
+	}
+
+	for _, destination := range b.destinations {
+		if err := b.leases.acquire(destination); err != nil {
+			continue
+		}
+
+		if err := destination.Remote.UploadCatalog(data); err != nil {
+			b.logger.With(zap.String("Destination", destination.Name)).With(zap.Error(err)).
+				Error("unable to upload catalog")
+		}
+
+		b.leases.release(destination)
+	}
+}
+
+// fileHashes считает md5 и sha256 файла за одно чтение; содержимое файла
+// также получают writers
+func fileHashes(path string, writers ...io.Writer) (string, string, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	defer file.Close()
+
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+
+	writers = append(writers, md5Hash, sha256Hash)
+
+	if _, err := io.Copy(io.MultiWriter(writers...), file); err != nil {
+		return "", "", err
+	}
+
+	return hex.EncodeToString(md5Hash.Sum(nil)), hex.EncodeToString(sha256Hash.Sum(nil)), nil
+}
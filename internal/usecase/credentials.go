@@ -0,0 +1,115 @@
+package usecase
+
+import (
+	"go.uber.org/zap"
+	"time"
+	"yd_backup/internal/models"
+)
+
+// Состояния токена аккаунта для уведомлений
+const (
+	TokenExpiresSoon   = "expires soon"
+	TokenExpired       = "expired"
+	TokenRefreshFailed = "refresh failed"
+)
+
+// TokenCheck - сохраненный токен аккаунта, который скоро истечет или уже
+// истек. Detail - причина ошибки обновления
+type TokenCheck struct {
+	Account   string    `json:"account"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Result    string    `json:"result"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// CredentialsChecker проверяет сохраненные токены аккаунтов и обновляет
+// истекшие, если это возможно
+type CredentialsChecker interface {
+	CheckCredentials() []TokenCheck
+}
+
+func (b *BackupService) SetCredentialsChecker(checker CredentialsChecker) {
+	b.credentials = checker
+}
+
+// CheckCredentials проверяет токены аккаунтов и уведомляет только об
+// изменении их состояния: об истекающем токене сообщают один раз, повторно -
+// когда он истечет или не обновится. Последнее сообщенное состояние хранится
+// в состоянии под ключом account:<имя>, исправный токен его сбрасывает
+func (b *BackupService) CheckCredentials() []TokenCheck {
+	if b.credentials == nil {
+		return nil
+	}
+
+	checks := b.credentials.CheckCredentials()
+
+	if b.state == nil || b.setting.Notify.Policy == models.NotifyNever {
+		b.NotifyCredentials(checks)
+		return checks
+	}
+
+	results := make(map[string]TokenCheck)
+
+	for _, check := range checks {
+		results[check.Account] = check
+	}
+
+	var changed []TokenCheck
+
+	for _, account := range b.setting.GetAccounts() {
+		key := accountStateKey(account.Name)
+
+		state, _ := b.state.Get(key)
+		check, failed := results[account.Name]
+
+		if state.TokenNotified == check.Result {
+			continue
+		}
+
+		if failed {
+			changed = append(changed, check)
+		}
+
+		state.TokenNotified = check.Result
+
+		if err := b.state.Set(key, state); err != nil {
+			b.logger.With(zap.String("Account", account.Name)).With(zap.Error(err)).
+				Error("unable to save account state")
+		}
+	}
+
+	b.NotifyCredentials(changed)
+
+	return checks
+}
+
+func accountStateKey(account string) string {
+	return "account:" + account
+}
+
+// NotifyCredentials уведомляет об истекающих и истекших токенах, если
+// политика уведомлений не never. Истекший токен означает, что загрузки в
+// назначения аккаунта не пройдут до нового входа
+func (b *BackupService) NotifyCredentials(checks []TokenCheck) {
+	if len(checks) == 0 || b.setting.Notify.Policy == models.NotifyNever {
+		return
+	}
+
+	status := StatusPartial
+
+	for _, check := range checks {
+		if check.Result != TokenExpiresSoon {
+			status = StatusFailed
+		}
+	}
+
+	now := time.Now()
+
+	b.notify(Report{
+		Kind:     ReportCredentials,
+		Status:   status,
+		Started:  now,
+		Finished: now,
+		Tokens:   checks,
+	})
+}
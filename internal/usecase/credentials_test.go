@@ -0,0 +1,122 @@
+package usecase
+
+import (
+	"go.uber.org/zap"
+	"sync"
+	"testing"
+	"time"
+	"yd_backup/internal/models"
+)
+
+// memoryState - состояние заданий в памяти, считает записи
+type memoryState struct {
+	mu     sync.Mutex
+	states map[string]models.JobState
+	sets   int
+}
+
+func (s *memoryState) Get(job string) (models.JobState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[job]
+
+	return state, ok
+}
+
+func (s *memoryState) Set(job string, state models.JobState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.states == nil {
+		s.states = make(map[string]models.JobState)
+	}
+
+	s.states[job] = state
+	s.sets++
+
+	return nil
+}
+
+// recordNotifier запоминает отправленные отчеты
+type recordNotifier struct {
+	mu      sync.Mutex
+	reports []Report
+}
+
+func (n *recordNotifier) Name() string {
+	return "record"
+}
+
+func (n *recordNotifier) Notify(report Report) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.reports = append(n.reports, report)
+
+	return nil
+}
+
+func (n *recordNotifier) count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return len(n.reports)
+}
+
+// fixedChecker возвращает заданные проверки токенов
+type fixedChecker struct {
+	checks []TokenCheck
+}
+
+func (c *fixedChecker) CheckCredentials() []TokenCheck {
+	return c.checks
+}
+
+func TestCheckCredentialsNotifiesOnChange(t *testing.T) {
+	setting := models.Setting{Accounts: []models.Account{{Name: "org1"}, {Name: "org2"}}}
+
+	checker := &fixedChecker{}
+	notifier := &recordNotifier{}
+	state := &memoryState{}
+
+	service := NewBackupService(setting, nil, nil, zap.NewNop())
+	service.SetCredentialsChecker(checker)
+	service.SetState(state)
+	service.AddNotifier(notifier)
+
+	expires := time.Now().Add(24 * time.Hour)
+
+	steps := []struct {
+		name   string
+		checks []TokenCheck
+		want   int
+	}{
+		{"healthy", nil, 0},
+		{"expires soon", []TokenCheck{{Account: "org1", ExpiresAt: expires, Result: TokenExpiresSoon}}, 1},
+		{"still expires soon", []TokenCheck{{Account: "org1", ExpiresAt: expires, Result: TokenExpiresSoon}}, 1},
+		{"expired", []TokenCheck{{Account: "org1", ExpiresAt: expires, Result: TokenExpired}}, 2},
+		{"still expired", []TokenCheck{{Account: "org1", ExpiresAt: expires, Result: TokenExpired}}, 2},
+		{"second account", []TokenCheck{
+			{Account: "org1", ExpiresAt: expires, Result: TokenExpired},
+			{Account: "org2", ExpiresAt: expires, Result: TokenRefreshFailed},
+		}, 3},
+		{"relogin", nil, 3},
+		{"expires soon again", []TokenCheck{{Account: "org1", ExpiresAt: expires, Result: TokenExpiresSoon}}, 4},
+	}
+
+	for _, step := range steps {
+		checker.checks = step.checks
+
+		service.CheckCredentials()
+
+		if notifier.count() != step.want {
+			t.Fatalf("%s: notifications = %d, want %d", step.name, notifier.count(), step.want)
+		}
+	}
+
+	// В отчет попадает только изменившийся токен
+	if tokens := notifier.reports[2].Tokens; len(tokens) != 1 || tokens[0].Account != "org2" {
+		t.Errorf("tokens = %+v, want only org2", tokens)
+	}
+}
@@ -0,0 +1,53 @@
+package usecase
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"yd_backup/internal/models"
+)
+
+// remoteName возвращает путь копии backupPath задания files относительно
+// папки назначения. Время копии берется из имени локального файла, поэтому
+// повторная загрузка из журнала попадает по тому же пути
+func (b *BackupService) remoteName(files models.Files, destination Destination, backupPath string) string {
+	file := destination.RemoteName(backupPath)
+
+	if files.RemotePath == "" {
+		return file
+	}
+
+	id := filepath.Base(backupPath)
+
+	created := time.Now()
+
+	if match := backupName.FindStringSubmatch(id); match != nil {
+		if parsed, err := time.ParseInLocation(backupTimestampLayout, match[2], time.Local); err == nil {
+			created = parsed
+		}
+	}
+
+	host, _ := os.Hostname()
+
+	return models.RenderRemotePath(files.RemotePath, models.RemoteValues{
+		Job:     files.Name,
+		Host:    host,
+		Name:    strings.TrimSuffix(id, filepath.Ext(id)),
+		Ext:     strings.TrimPrefix(filepath.Ext(id), "."),
+		File:    file,
+		Created: created,
+	})
+}
+
+// job возвращает задание по имени; для удаленного из конфигурации задания -
+// задание без шаблона пути
+func (b *BackupService) job(name string) models.Files {
+	for _, files := range b.setting.Files {
+		if files.Name == name {
+			return files
+		}
+	}
+
+	return models.Files{Name: name}
+}
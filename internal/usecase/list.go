@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"yd_backup/internal/models"
+)
+
+// Listing - копии в одном назначении
+type Listing struct {
+	Destination string              `json:"destination"`
+	Files       []models.BackupFile `json:"files"`
+	Error       string              `json:"error,omitempty"`
+}
+
+// List возвращает загруженные копии из каталога для назначений с указанными
+// именами, а без имен - для всех назначений
+func (b *BackupService) List(names []string) ([]Listing, Status, error) {
+	if b.catalog == nil {
+		return b.ListRemote(names)
+	}
+
+	destinations, err := b.selectDestinations(names)
+
+	if err != nil {
+		return nil, StatusFailed, err
+	}
+
+	entries, err := b.catalog.Entries()
+
+	if err != nil {
+		return nil, StatusFailed, err
+	}
+
+	var result []Listing
+
+	for _, destination := range destinations {
+		listing := Listing{Destination: destination.Name, Files: []models.BackupFile{}}
+
+		for _, entry := range entries {
+			catalogCopy := entry.Copy(destination.Name)
+
+			if catalogCopy == nil || catalogCopy.Status != models.CopyUploaded {
+				continue
+			}
+
+			listing.Files = append(listing.Files, models.BackupFile{
+				Name:    catalogCopy.RemoteName(),
+				Path:    catalogCopy.RemotePath,
+				Size:    entry.Size,
+				Created: entry.Created,
+				Md5:     entry.Md5,
+				Pinned:  entry.Pinned,
+			})
+		}
+
+		result = append(result, listing)
+	}
+
+	return result, StatusSuccess, nil
+}
+
+// ListRemote запрашивает список копий у самих назначений с указанными
+// именами, а без имен - у всех назначений
+func (b *BackupService) ListRemote(names []string) ([]Listing, Status, error) {
+	destinations, err := b.selectDestinations(names)
+
+	if err != nil {
+		return nil, StatusFailed, err
+	}
+
+	var result []Listing
+
+	success := 0
+
+	for _, destination := range destinations {
+		listing := Listing{Destination: destination.Name}
+
+		files, err := destination.Remote.ListBackup()
+
+		if err != nil {
+			b.logger.With(zap.String("Destination", destination.Name)).With(zap.Error(err)).
+				Error("unable to list backups")
+			listing.Error = err.Error()
+		} else {
+			listing.Files = b.markPinned(destination.Name, files)
+			success++
+		}
+
+		result = append(result, listing)
+	}
+
+	return result, newStatus(success, len(destinations)), nil
+}
+
+// Restore скачивает копию name из назначения в targetPath. Если targetPath
+// пуст или является директорией, файл сохраняется в ней под своим именем.
+// name - имя копии в назначении или ID записи каталога; скачанный файл
+// сверяется с md5 из каталога, а для копий не из каталога - с md5 из
+// описания копии в назначении
+func (b *BackupService) Restore(destinationName string, name string, targetPath string) (string, error) {
+	destinations, err := b.selectDestinations([]string{destinationName})
+
+	if err != nil {
+		return "", err
+	}
+
+	fileName := filepath.Base(name)
+
+	var expectedMd5 string
+
+	if entry, found := b.findEntry(destinationName, name); found {
+		// Локальное имя копии сохраняет расширение исходного файла
+		name, fileName, expectedMd5 = entry.Copy(destinationName).RemoteName(), entry.ID, entry.Md5
+	} else if file, found := b.describedFile(destinations[0], name); found {
+		name, fileName, expectedMd5 = file.Name, file.Metadata.ID, file.Metadata.Md5
+	} else {
+		b.logger.With(zap.String("Name", name)).Warn("Backup is neither in catalog nor described in destination, restored file is not checked")
+	}
+
+	if targetPath == "" {
+		targetPath = "."
+	}
+
+	if info, err := os.Stat(targetPath); err == nil && info.IsDir() {
+		targetPath = filepath.Join(targetPath, fileName)
+	}
+
+	// Файл скачивается рядом и заменяет targetPath только после сверки md5
+	downloadPath := targetPath + ".part"
+
+	if err := destinations[0].Remote.DownloadBackup(name, downloadPath); err != nil {
+		return "", fmt.Errorf("unable to restore %s from %s: %v", name, destinationName, err)
+	}
+
+	if expectedMd5 != "" {
+		if sum := fileMd5(downloadPath); sum != expectedMd5 {
+			os.Remove(downloadPath)
+			return "", fmt.Errorf("restored file %s is corrupted: md5 %s, expected %s", targetPath, sum, expectedMd5)
+		}
+	}
+
+	if err := os.Rename(downloadPath, targetPath); err != nil {
+		os.Remove(downloadPath)
+		return "", fmt.Errorf("unable to restore %s: %v", targetPath, err)
+	}
+
+	b.logger.With(zap.String("Destination", destinationName)).With(zap.String("Path", targetPath)).
+		Info("Backup restored")
+
+	return targetPath, nil
+}
+
+func (b *BackupService) selectDestinations(names []string) ([]Destination, error) {
+	if len(names) == 0 {
+		return b.destinations, nil
+	}
+
+	var result []Destination
+
+	for _, name := range names {
+		found := false
+
+		for _, destination := range b.destinations {
+			if destination.Name == name {
+				result = append(result, destination)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown destination %s", name)
+		}
+	}
+
+	return result, nil
+}
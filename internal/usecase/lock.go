@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"sync"
+	"time"
+	"yd_backup/internal/models"
+)
+
+// Locker - локальные блокировки между процессами на одном хосте
+type Locker interface {
+	Lock(name string) (func(), error)
+}
+
+// Leaser - назначение, поддерживающее аренду папки между хостами
+type Leaser interface {
+	AcquireLease(lease models.Lease) error
+	ReleaseLease(lease models.Lease) error
+}
+
+func (b *BackupService) SetLocker(locker Locker) {
+	b.locker = locker
+}
+
+// EnableLeases включает аренду папок назначений, которые ее поддерживают.
+// Аренда продлевается каждую треть ttl, пока она нужна хотя бы одной операции
+func (b *BackupService) EnableLeases(ttl time.Duration) {
+	host, _ := os.Hostname()
+
+	b.leases = &leaseManager{
+		host:   host,
+		owner:  fmt.Sprintf("%s:%d", host, os.Getpid()),
+		ttl:    ttl,
+		held:   make(map[string]*heldLease),
+		logger: b.logger,
+	}
+}
+
+func (b *BackupService) lock(name string) (func(), error) {
+	if b.locker == nil {
+		return func() {}, nil
+	}
+
+	return b.locker.Lock(name)
+}
+
+type heldLease struct {
+	count int
+	stop  chan struct{}
+}
+
+type leaseManager struct {
+	mu     sync.Mutex
+	host   string
+	owner  string
+	ttl    time.Duration
+	held   map[string]*heldLease
+	logger *zap.Logger
+}
+
+func (m *leaseManager) newLease() models.Lease {
+	now := time.Now()
+
+	return models.Lease{
+		Owner:   m.owner,
+		Host:    m.host,
+		Pid:     os.Getpid(),
+		Renewed: now,
+		Expires: now.Add(m.ttl),
+	}
+}
+
+// acquire берет аренду папки назначения или увеличивает счетчик уже взятой
+func (m *leaseManager) acquire(destination Destination) error {
+	leaser, ok := destination.Remote.(Leaser)
+
+	if m == nil || !ok {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if held, ok := m.held[destination.Name]; ok {
+		held.count++
+		return nil
+	}
+
+	if err := leaser.AcquireLease(m.newLease()); err != nil {
+		return err
+	}
+
+	held := &heldLease{count: 1, stop: make(chan struct{})}
+
+	m.held[destination.Name] = held
+
+	go m.renew(destination, leaser, held.stop)
+
+	return nil
+}
+
+func (m *leaseManager) release(destination Destination) {
+	leaser, ok := destination.Remote.(Leaser)
+
+	if m == nil || !ok {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	held, ok := m.held[destination.Name]
+
+	if !ok {
+		return
+	}
+
+	held.count--
+
+	if held.count > 0 {
+		return
+	}
+
+	close(held.stop)
+	delete(m.held, destination.Name)
+
+	if err := leaser.ReleaseLease(m.newLease()); err != nil {
+		m.logger.With(zap.String("Destination", destination.Name)).With(zap.Error(err)).
+			Warn("unable to release lease")
+	}
+}
+
+func (m *leaseManager) renew(destination Destination, leaser Leaser, stop chan struct{}) {
+	ticker := time.NewTicker(m.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := leaser.AcquireLease(m.newLease()); err != nil {
+				m.logger.With(zap.String("Destination", destination.Name)).With(zap.Error(err)).
+					Error("unable to renew lease")
+			}
+		}
+	}
+}
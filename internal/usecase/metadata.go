@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"go.uber.org/zap"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+	"yd_backup/internal/models"
+)
+
+// MetadataWriter - назначение, которое хранит описание копии вместе с файлом
+type MetadataWriter interface {
+	SetMetadata(name string, metadata models.BackupMetadata) error
+}
+
+// SetVersion задает версию программы, которая записывается в описание копий
+func (b *BackupService) SetVersion(version string) {
+	b.version = version
+}
+
+// describe записывает в назначение описание загруженной копии по записи
+// каталога. Ошибка не делает загрузку неудачной: без описания копия
+// опознается по имени файла
+func (b *BackupService) describe(destination Destination, backupPath string, name string) {
+	writer, ok := destination.Remote.(MetadataWriter)
+
+	if !ok {
+		return
+	}
+
+	metadata, ok := b.metadata(filepath.Base(backupPath))
+
+	if !ok {
+		return
+	}
+
+	if err := writer.SetMetadata(name, metadata); err != nil {
+		b.logger.With(zap.String("Destination", destination.Name)).With(zap.String("Path", backupPath)).
+			With(zap.Error(err)).Warn("unable to write backup metadata")
+	}
+}
+
+// metadata собирает описание копии из записи каталога с ID id
+func (b *BackupService) metadata(id string) (models.BackupMetadata, bool) {
+	if b.catalog == nil {
+		return models.BackupMetadata{}, false
+	}
+
+	entries, err := b.catalog.Entries()
+
+	if err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to read catalog")
+		return models.BackupMetadata{}, false
+	}
+
+	host, _ := os.Hostname()
+
+	for _, entry := range entries {
+		if entry.ID != id {
+			continue
+		}
+
+		return models.BackupMetadata{
+			ID:          entry.ID,
+			Job:         entry.Job,
+			Host:        host,
+			Source:      entry.Source,
+			Created:     entry.Created,
+			Size:        entry.Size,
+			Md5:         entry.Md5,
+			Sha256:      entry.Sha256,
+			Compression: entry.Compression,
+			Encryption:  entry.Encryption,
+			Version:     b.version,
+		}, true
+	}
+
+	return models.BackupMetadata{}, false
+}
+
+// describedFile ищет в назначении копию с описанием по пути относительно
+// папки назначения, полному пути или ID из описания
+func (b *BackupService) describedFile(destination Destination, name string) (models.BackupFile, bool) {
+	files, err := destination.Remote.ListBackup()
+
+	if err != nil {
+		b.logger.With(zap.String("Destination", destination.Name)).With(zap.Error(err)).
+			Error("unable to list backups")
+		return models.BackupFile{}, false
+	}
+
+	for _, file := range files {
+		if file.Metadata == nil {
+			continue
+		}
+
+		if file.Name == filepath.ToSlash(name) || models.SamePath(file.Path, name) || file.Metadata.ID == name {
+			return file, true
+		}
+	}
+
+	return models.BackupFile{}, false
+}
+
+// fileJob возвращает задание копии в назначении: из описания копии, а если
+// его нет - из имени файла
+func fileJob(file models.BackupFile) (string, bool) {
+	if file.Metadata != nil && file.Metadata.Job != "" {
+		return file.Metadata.Job, true
+	}
+
+	match := backupName.FindStringSubmatch(path.Base(file.Name))
+
+	if match == nil {
+		return "", false
+	}
+
+	return match[1], true
+}
+
+// fileCreated возвращает время создания копии в назначении: из описания
+// копии, а если его нет - время появления файла
+func fileCreated(file models.BackupFile) time.Time {
+	if file.Metadata != nil && !file.Metadata.Created.IsZero() {
+		return file.Metadata.Created
+	}
+
+	return file.Created
+}
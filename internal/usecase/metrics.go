@@ -0,0 +1,236 @@
+package usecase
+
+import (
+	"go.uber.org/zap"
+	"strconv"
+	"time"
+	"yd_backup/internal/models"
+)
+
+// Типы метрик в терминах Prometheus
+const (
+	MetricGauge   = "gauge"
+	MetricCounter = "counter"
+)
+
+// Имена метрик
+const (
+	MetricJobLastRun         = "yd_backup_job_last_run_timestamp_seconds"
+	MetricJobLastSuccess     = "yd_backup_job_last_success_timestamp_seconds"
+	MetricJobLastStatus      = "yd_backup_job_last_status"
+	MetricJobDuration        = "yd_backup_job_duration_seconds"
+	MetricJobBytes           = "yd_backup_job_bytes"
+	MetricJobStale           = "yd_backup_job_stale"
+	MetricUploadLastSuccess  = "yd_backup_upload_last_success_timestamp_seconds"
+	MetricUploadStatus       = "yd_backup_upload_status"
+	MetricUploadBytes        = "yd_backup_upload_bytes_total"
+	MetricUploadDuration     = "yd_backup_upload_duration_seconds"
+	MetricRetentionDeleted   = "yd_backup_retention_deleted_total"
+	MetricYandexRequests     = "yd_backup_yandex_api_requests_total"
+	MetricYandexQuotaTotal   = "yd_backup_yandex_disk_total_bytes"
+	MetricYandexQuotaUsed    = "yd_backup_yandex_disk_used_bytes"
+	MetricYandexQuotaTrash   = "yd_backup_yandex_disk_trash_bytes"
+	MetricLocalDestination   = "local"
+	metricUnknownStatusValue = -1
+)
+
+// Metrics - хранилище метрик. Метки передаются парами имя, значение
+type Metrics interface {
+	Describe(name string, help string, kind string)
+	Set(name string, value float64, labels ...string)
+	Add(name string, value float64, labels ...string)
+}
+
+// QuotaReporter - назначение, которое сообщает занятое и общее место
+// своего аккаунта
+type QuotaReporter interface {
+	Quota() (models.Quota, error)
+}
+
+// DescribeMetrics задает описания всех метрик сервиса
+func DescribeMetrics(metrics Metrics) {
+	metrics.Describe(MetricJobLastRun, "Start time of the last run of the job.", MetricGauge)
+	metrics.Describe(MetricJobLastSuccess, "Start time of the last successful or partially successful run of the job.", MetricGauge)
+	metrics.Describe(MetricJobLastStatus, "Status of the last run of the job: 0 success, 1 partial, 2 failed.", MetricGauge)
+	metrics.Describe(MetricJobDuration, "Duration of the last run of the job.", MetricGauge)
+	metrics.Describe(MetricJobBytes, "Size of the backup made by the last run of the job.", MetricGauge)
+	metrics.Describe(MetricJobStale, "1 if the last successful backup of the job is older than max_age.", MetricGauge)
+	metrics.Describe(MetricUploadLastSuccess, "Upload time of the newest backup of the job in the destination.", MetricGauge)
+	metrics.Describe(MetricUploadStatus, "Result of the last upload of the job to the destination: 1 uploaded, 0 failed.", MetricGauge)
+	metrics.Describe(MetricUploadBytes, "Bytes uploaded to the destination.", MetricCounter)
+	metrics.Describe(MetricUploadDuration, "Duration of the last upload of the job to the destination.", MetricGauge)
+	metrics.Describe(MetricRetentionDeleted, "Backups deleted by retention.", MetricCounter)
+	metrics.Describe(MetricYandexRequests, "Requests to Yandex Disk API by response code, 0 for network errors.", MetricCounter)
+	metrics.Describe(MetricYandexQuotaTotal, "Total space of Yandex Disk.", MetricGauge)
+	metrics.Describe(MetricYandexQuotaUsed, "Used space of Yandex Disk.", MetricGauge)
+	metrics.Describe(MetricYandexQuotaTrash, "Space used by Yandex Disk trash.", MetricGauge)
+}
+
+// ObserveRequests возвращает наблюдателя запросов к API аккаунта account
+func ObserveRequests(metrics Metrics, account string) func(method string, statusCode int) {
+	return func(method string, statusCode int) {
+		metrics.Add(MetricYandexRequests, 1,
+			"account", account, "method", method, "code", strconv.Itoa(statusCode))
+	}
+}
+
+func (b *BackupService) SetMetrics(metrics Metrics) {
+	b.metrics = metrics
+}
+
+// RefreshMetrics обновляет метрики, которые не зависят от текущего запуска:
+// итоги заданий из состояния, последние загрузки из каталога и квоты дисков
+func (b *BackupService) RefreshMetrics() {
+	if b.metrics == nil {
+		return
+	}
+
+	b.observeState()
+	b.observeCatalog()
+	b.observeQuota()
+}
+
+// observeJobs записывает метрики заданий и загрузок текущего запуска
+func (b *BackupService) observeJobs(outcomes []JobOutcome) {
+	if b.metrics == nil {
+		return
+	}
+
+	for _, outcome := range outcomes {
+		b.metrics.Set(MetricJobDuration, outcome.Duration().Seconds(), "job", outcome.Job)
+		b.metrics.Set(MetricJobBytes, float64(outcome.Bytes), "job", outcome.Job)
+
+		for _, result := range outcome.Destinations {
+			labels := []string{"job", outcome.Job, "destination", result.Name}
+
+			b.metrics.Set(MetricUploadDuration, result.Duration.Seconds(), labels...)
+
+			if result.Err != nil {
+				b.metrics.Set(MetricUploadStatus, 0, labels...)
+				continue
+			}
+
+			b.metrics.Set(MetricUploadStatus, 1, labels...)
+			b.metrics.Add(MetricUploadBytes, float64(result.Bytes), labels...)
+		}
+	}
+}
+
+// observeRetention учитывает копии, удаленные очисткой из назначения
+func (b *BackupService) observeRetention(destination string, paths []string) {
+	if b.metrics == nil {
+		return
+	}
+
+	b.metrics.Add(MetricRetentionDeleted, float64(len(paths)), "destination", destination)
+}
+
+// observeState записывает последние итоги всех заданий конфигурации, в том
+// числе не запускавшихся в этот раз
+func (b *BackupService) observeState() {
+	if b.state == nil {
+		return
+	}
+
+	for _, files := range b.setting.Files {
+		state, ok := b.state.Get(files.Name)
+
+		if !ok {
+			continue
+		}
+
+		b.metrics.Set(MetricJobLastRun, timestamp(state.LastRun), "job", files.Name)
+		b.metrics.Set(MetricJobLastStatus, statusValue(state.LastStatus), "job", files.Name)
+
+		if state.LastSuccess.IsZero() {
+			continue
+		}
+
+		b.metrics.Set(MetricJobLastSuccess, timestamp(state.LastSuccess), "job", files.Name)
+
+		if files.MaxAge.Duration > 0 {
+			b.metrics.Set(MetricJobStale, boolValue(time.Since(state.LastSuccess) > files.MaxAge.Duration), "job", files.Name)
+		}
+	}
+}
+
+// observeCatalog записывает время последней загрузки каждого задания в
+// каждое назначение по каталогу
+func (b *BackupService) observeCatalog() {
+	if b.catalog == nil {
+		return
+	}
+
+	entries, err := b.catalog.Entries()
+
+	if err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to read catalog for metrics")
+		return
+	}
+
+	type key struct {
+		job         string
+		destination string
+	}
+
+	latest := make(map[key]time.Time)
+
+	for _, entry := range entries {
+		for _, catalogCopy := range entry.Copies {
+			if catalogCopy.Status != models.CopyUploaded {
+				continue
+			}
+
+			k := key{job: entry.Job, destination: catalogCopy.Destination}
+
+			if catalogCopy.Uploaded.After(latest[k]) {
+				latest[k] = catalogCopy.Uploaded
+			}
+		}
+	}
+
+	for k, uploaded := range latest {
+		b.metrics.Set(MetricUploadLastSuccess, timestamp(uploaded), "job", k.job, "destination", k.destination)
+	}
+}
+
+// observeQuota запрашивает квоту один раз для каждого аккаунта Yandex Disk
+func (b *BackupService) observeQuota() {
+	accounts := make(map[string]bool)
+
+	for _, destination := range b.destinations {
+		reporter, ok := destination.Remote.(QuotaReporter)
+
+		if !ok || accounts[destination.Account] {
+			continue
+		}
+
+		accounts[destination.Account] = true
+
+		quota, err := reporter.Quota()
+
+		if err != nil {
+			b.logger.With(zap.String("Destination", destination.Name)).With(zap.Error(err)).
+				Error("unable to get disk quota")
+			continue
+		}
+
+		b.metrics.Set(MetricYandexQuotaTotal, float64(quota.Total), "account", destination.Account)
+		b.metrics.Set(MetricYandexQuotaUsed, float64(quota.Used), "account", destination.Account)
+		b.metrics.Set(MetricYandexQuotaTrash, float64(quota.Trash), "account", destination.Account)
+	}
+}
+
+func timestamp(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+func statusValue(status string) float64 {
+	for _, s := range []Status{StatusSuccess, StatusPartial, StatusFailed} {
+		if s.String() == status {
+			return float64(s)
+		}
+	}
+
+	return metricUnknownStatusValue
+}
@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"go.uber.org/zap"
+	"os"
+	"time"
+	"yd_backup/internal/models"
+)
+
+// Виды отчетов для уведомлений
+const (
+	ReportBackup      = "backup"
+	ReportTestRestore = "test restore"
+	ReportStale       = "stale backup"
+	ReportCredentials = "credentials"
+)
+
+// Notifier - канал уведомлений об итогах запусков
+type Notifier interface {
+	Name() string
+	Notify(report Report) error
+}
+
+// Report - итог запуска для уведомления. Triggered - задания, из-за которых
+// отправлено уведомление по их политике
+type Report struct {
+	Kind      string         `json:"kind"`
+	Host      string         `json:"host"`
+	Status    Status         `json:"status"`
+	Started   time.Time      `json:"started"`
+	Finished  time.Time      `json:"finished"`
+	Jobs      []JobOutcome   `json:"jobs,omitempty"`
+	Checks    []RestoreCheck `json:"checks,omitempty"`
+	Stale     []StaleJob     `json:"stale,omitempty"`
+	Tokens    []TokenCheck   `json:"tokens,omitempty"`
+	Triggered []string       `json:"triggered,omitempty"`
+}
+
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Status - итог задания: неуспешное задание - failed, успешное с ошибками
+// необязательных назначений или подозрительной копией - partial
+func (o *JobOutcome) Status() Status {
+	if o.Err != nil {
+		return StatusFailed
+	}
+
+	if len(o.Anomalies) > 0 {
+		return StatusPartial
+	}
+
+	for _, result := range o.Destinations {
+		if result.Err != nil {
+			return StatusPartial
+		}
+	}
+
+	return StatusSuccess
+}
+
+func (b *BackupService) AddNotifier(notifier Notifier) {
+	b.notifiers = append(b.notifiers, notifier)
+}
+
+// SetState задает хранилище последних итогов заданий, по нему политика
+// recovery определяет, что задание восстановилось после ошибки
+func (b *BackupService) SetState(state StateStore) {
+	b.state = state
+}
+
+// notifyBackup записывает итоги заданий и отправляет уведомление, если хотя
+// бы одно задание требует его по своей политике
+func (b *BackupService) notifyBackup(outcomes []JobOutcome, started time.Time, status Status) {
+	var triggered []string
+
+	for i := range outcomes {
+		outcome := &outcomes[i]
+
+		current := outcome.Status()
+
+		var previous models.JobState
+
+		if b.state != nil {
+			previous, _ = b.state.Get(outcome.Job)
+		}
+
+		if b.jobPolicy(outcome.Job).triggers(previous.LastStatus, current) {
+			triggered = append(triggered, outcome.Job)
+		}
+
+		if b.state == nil {
+			continue
+		}
+
+		previous.LastRun = outcome.Started
+		previous.LastStatus = current.String()
+
+		// Частичный успех - копия есть хотя бы в обязательных назначениях
+		if current != StatusFailed {
+			previous.LastSuccess = outcome.Started
+		}
+
+		if err := b.state.Set(outcome.Job, previous); err != nil {
+			b.logger.With(zap.Error(err)).Error("unable to save job state")
+		}
+	}
+
+	if len(triggered) == 0 {
+		return
+	}
+
+	b.notify(Report{
+		Kind:      ReportBackup,
+		Status:    status,
+		Started:   started,
+		Finished:  time.Now(),
+		Jobs:      outcomes,
+		Triggered: triggered,
+	})
+}
+
+// notifyTestRestore отправляет уведомление о неудачной проверке восстановлением
+func (b *BackupService) notifyTestRestore(checks []RestoreCheck, started time.Time, status Status) {
+	if status == StatusSuccess || b.setting.Notify.Policy == models.NotifyNever {
+		return
+	}
+
+	b.notify(Report{
+		Kind:     ReportTestRestore,
+		Status:   status,
+		Started:  started,
+		Finished: time.Now(),
+		Checks:   checks,
+	})
+}
+
+func (b *BackupService) notify(report Report) {
+	report.Host, _ = os.Hostname()
+
+	for _, notifier := range b.notifiers {
+		logger := b.logger.With(zap.String("Notifier", notifier.Name()))
+
+		if err := notifier.Notify(report); err != nil {
+			logger.With(zap.Error(err)).Error("unable to send notification")
+			continue
+		}
+
+		logger.With(zap.String("status", report.Status.String())).Info("Notification sent")
+	}
+}
+
+type policy string
+
+func (b *BackupService) jobPolicy(name string) policy {
+	for _, files := range b.setting.Files {
+		if files.Name == name && files.Notify != "" {
+			return policy(files.Notify)
+		}
+	}
+
+	return policy(b.setting.Notify.Policy)
+}
+
+// triggers сообщает, нужно ли уведомление при переходе задания из
+// previous (пусто, если задание еще не запускалось) в current
+func (p policy) triggers(previous string, current Status) bool {
+	switch p {
+	case models.NotifyAlways:
+		return true
+	case models.NotifyFailure:
+		return current != StatusSuccess
+	case models.NotifyRecovery:
+		return previous != "" && previous != StatusSuccess.String() && current == StatusSuccess
+	default:
+		return false
+	}
+}
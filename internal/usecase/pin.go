@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"time"
+	"yd_backup/internal/models"
+)
+
+// Pinner - назначение, которое хранит отметку о закреплении рядом с копией
+type Pinner interface {
+	SetPinned(name string, pinned bool, reason string) error
+}
+
+// Pin закрепляет копию name в каталоге и в назначениях, поддерживающих
+// отметку: очистка ее больше не удаляет. name - ID записи каталога или имя
+// копии в назначении
+func (b *BackupService) Pin(name string, reason string) (models.CatalogEntry, Status, error) {
+	return b.setPinned(name, true, reason)
+}
+
+// Unpin снимает закрепление копии name, после чего она удаляется очисткой
+// по обычным правилам
+func (b *BackupService) Unpin(name string) (models.CatalogEntry, Status, error) {
+	return b.setPinned(name, false, "")
+}
+
+func (b *BackupService) setPinned(name string, pinned bool, reason string) (models.CatalogEntry, Status, error) {
+	if b.catalog == nil {
+		return models.CatalogEntry{}, StatusFailed, fmt.Errorf("catalog is not configured")
+	}
+
+	entry, found := b.catalogEntry(name)
+
+	if !found {
+		return models.CatalogEntry{}, StatusFailed, fmt.Errorf("backup %s is not in catalog", name)
+	}
+
+	update := func(entry *models.CatalogEntry) {
+		entry.Pinned = pinned
+		entry.PinReason = reason
+		entry.PinnedAt = time.Time{}
+
+		if pinned {
+			entry.PinnedAt = time.Now()
+		}
+	}
+
+	if err := b.catalog.Update(entry.ID, update); err != nil {
+		return entry, StatusFailed, fmt.Errorf("unable to update catalog: %v", err)
+	}
+
+	update(&entry)
+
+	// Отметка в каталоге уже сделана и считается одним из мест
+	total, success := 1, 1
+
+	for _, catalogCopy := range entry.Copies {
+		destination, ok := b.destination(catalogCopy.Destination)
+
+		if !ok || catalogCopy.Status != models.CopyUploaded {
+			continue
+		}
+
+		pinner, ok := destination.Remote.(Pinner)
+
+		if !ok {
+			continue
+		}
+
+		total++
+
+		if err := pinner.SetPinned(catalogCopy.RemoteName(), pinned, reason); err != nil {
+			b.logger.With(zap.String("Destination", destination.Name)).With(zap.String("ID", entry.ID)).
+				With(zap.Error(err)).Error("unable to mark backup in destination")
+			continue
+		}
+
+		success++
+	}
+
+	b.logger.With(zap.String("ID", entry.ID)).With(zap.Bool("pinned", pinned)).With(zap.String("reason", reason)).
+		Info("Backup pin changed")
+
+	b.publishCatalog()
+
+	return entry, newStatus(success, total), nil
+}
+
+// catalogEntry ищет запись каталога по ID, по пути локальной копии или по
+// пути копии в любом назначении. Из нескольких записей с одним путем,
+// переиспользованным шаблоном, выбирается последняя
+func (b *BackupService) catalogEntry(name string) (models.CatalogEntry, bool) {
+	entries, err := b.catalog.Entries()
+
+	if err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to read catalog")
+		return models.CatalogEntry{}, false
+	}
+
+	var (
+		result models.CatalogEntry
+		found  bool
+	)
+
+	for _, entry := range entries {
+		if entry.ID == name || (entry.LocalPath != "" && models.SamePath(entry.LocalPath, name)) {
+			result, found = entry, true
+			continue
+		}
+
+		for _, catalogCopy := range entry.Copies {
+			if isCopyPath(catalogCopy, name) {
+				result, found = entry, true
+			}
+		}
+	}
+
+	return result, found
+}
+
+// markPinned отмечает закрепленными файлы назначения, закрепленные в каталоге
+func (b *BackupService) markPinned(destination string, files []models.BackupFile) []models.BackupFile {
+	if b.catalog == nil {
+		return files
+	}
+
+	entries, err := b.catalog.Entries()
+
+	if err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to read catalog")
+		return files
+	}
+
+	pinned := make(map[string]bool)
+
+	for _, entry := range entries {
+		if catalogCopy := entry.Copy(destination); catalogCopy != nil && entry.Pinned {
+			pinned[catalogCopy.RemoteName()] = true
+		}
+	}
+
+	for i := range files {
+		if pinned[files[i].Name] {
+			files[i].Pinned = true
+		}
+	}
+
+	return files
+}
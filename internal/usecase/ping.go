@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"fmt"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+	"yd_backup/internal/models"
+)
+
+// События проверки задания
+const (
+	PingStart   = "start"
+	PingSuccess = "success"
+	PingFail    = "fail"
+)
+
+// Pinger отправляет сигналы проверки задания в стиле healthchecks.io. runID
+// связывает start с success или fail одного запуска
+type Pinger interface {
+	Ping(url string, event string, runID string, body string) error
+}
+
+func (b *BackupService) SetPinger(pinger Pinger) {
+	b.pinger = pinger
+}
+
+// run выполняет задание между сигналами start и success или fail. Ошибка
+// отправки сигнала не влияет на итог задания
+func (b *BackupService) run(files models.Files, outcome *JobOutcome) {
+	outcome.RunID = newRunID()
+
+	b.ping(files, PingStart, outcome.RunID, fmt.Sprintf("%s started\n", files.Name))
+
+	b.backup(files, outcome)
+
+	outcome.Finished = time.Now()
+
+	event := PingSuccess
+
+	if outcome.Err != nil {
+		event = PingFail
+	}
+
+	b.ping(files, event, outcome.RunID, outcome.Log())
+}
+
+func (b *BackupService) ping(files models.Files, event string, runID string, body string) {
+	url := files.Ping.Value()
+
+	if b.pinger == nil || url == "" {
+		return
+	}
+
+	if err := b.pinger.Ping(url, event, runID, body); err != nil {
+		b.logger.With(zap.String("Job", files.Name)).With(zap.String("event", event)).With(zap.Error(err)).
+			Warn("unable to send ping")
+	}
+}
+
+// Log - краткий журнал запуска задания для тела сигнала проверки
+func (o *JobOutcome) Log() string {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "%s %s run %s started\n", o.Started.Local().Format(time.DateTime), o.Job, o.RunID)
+
+	if o.Bytes > 0 {
+		fmt.Fprintf(&builder, "local copy of %s: %d bytes in %s\n", o.Path, o.Bytes, o.CopyDuration.Round(time.Millisecond))
+	}
+
+	for _, result := range o.Destinations {
+		if result.Err != nil {
+			fmt.Fprintf(&builder, "upload to %s failed: %v\n", result.Name, result.Err)
+			continue
+		}
+
+		fmt.Fprintf(&builder, "upload to %s: %d bytes in %s\n", result.Name, result.Bytes, result.Duration.Round(time.Millisecond))
+	}
+
+	if o.Err != nil {
+		fmt.Fprintf(&builder, "error: %v\n", o.Err)
+	}
+
+	fmt.Fprintf(&builder, "%s %s %s in %s\n", o.Finished.Local().Format(time.DateTime), o.Job, o.Status(),
+		o.Duration().Round(time.Millisecond))
+
+	return builder.String()
+}
+
+// newRunID возвращает случайный UUID версии 4
+func newRunID() string {
+	var id [16]byte
+
+	rand.Read(id[:])
+
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:])
+}
@@ -0,0 +1,189 @@
+package usecase
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"os"
+	"time"
+	"yd_backup/internal/models"
+)
+
+// UploadQueue - журнал неподтвержденных загрузок
+type UploadQueue interface {
+	Add(upload models.Upload) error
+	Remove(path string, destination string) error
+	Pending() ([]models.Upload, error)
+}
+
+func (b *BackupService) SetQueue(queue UploadQueue) {
+	b.queue = queue
+}
+
+// upload загружает копию в назначение под путем name относительно папки
+// назначения, занимая аренду папки и слот загрузки
+func (b *BackupService) upload(destination Destination, backupPath string, name string) (time.Duration, error) {
+	if err := b.leases.acquire(destination); err != nil {
+		return 0, err
+	}
+
+	defer b.leases.release(destination)
+
+	b.uploadSlots <- struct{}{}
+	defer func() { <-b.uploadSlots }()
+
+	started := time.Now()
+
+	err := destination.Remote.UploadBackup(backupPath, name)
+
+	if err == nil {
+		b.describe(destination, backupPath, name)
+	}
+
+	return time.Since(started), err
+}
+
+// enqueue записывает загрузку в журнал до ее начала. Если процесс
+// прервется во время загрузки, она будет повторена, а локальная копия не
+// будет удалена до подтверждения
+func (b *BackupService) enqueue(files models.Files, backupPath string, destination string) models.Upload {
+	upload := models.Upload{
+		Path:        backupPath,
+		Job:         files.Name,
+		Destination: destination,
+		Created:     time.Now(),
+	}
+
+	if b.queue == nil {
+		return upload
+	}
+
+	if err := b.queue.Add(upload); err != nil {
+		b.logger.With(zap.String("Path", backupPath)).With(zap.Error(err)).Error("unable to queue upload")
+	}
+
+	return upload
+}
+
+// confirm удаляет из журнала подтвержденную загрузку, а неудачную оставляет
+// в нем с ошибкой для повтора
+func (b *BackupService) confirm(upload models.Upload, err error) models.Upload {
+	if b.queue == nil {
+		return upload
+	}
+
+	if err == nil {
+		b.dequeue(upload)
+		return upload
+	}
+
+	upload.Attempts++
+	upload.LastError = err.Error()
+	upload.LastAttempt = time.Now()
+
+	if err := b.queue.Add(upload); err != nil {
+		b.logger.With(zap.String("Path", upload.Path)).With(zap.Error(err)).Error("unable to update upload queue")
+	}
+
+	return upload
+}
+
+// running сообщает, выполняется ли задание в этом или другом процессе. Его
+// загрузки из журнала еще идут и не повторяются
+func (b *BackupService) running(job string) bool {
+	unlock, err := b.lock("job-" + job)
+
+	if err != nil {
+		return true
+	}
+
+	unlock()
+
+	return false
+}
+
+// RetryUploads повторяет неподтвержденные загрузки, начиная с самых старых.
+// После первой ошибки назначения его остальные загрузки ждут следующего
+// повтора. Одновременно выполняется только один повтор
+func (b *BackupService) RetryUploads() Status {
+	if b.queue == nil || !b.retry.TryLock() {
+		return StatusSuccess
+	}
+
+	defer b.retry.Unlock()
+
+	uploads, err := b.queue.Pending()
+
+	if err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to read upload queue")
+		return StatusFailed
+	}
+
+	if len(uploads) == 0 {
+		return StatusSuccess
+	}
+
+	b.logger.With(zap.Int("count", len(uploads))).Info("Retrying pending uploads")
+
+	offline := make(map[string]bool)
+	success := 0
+
+	for _, upload := range uploads {
+		logger := b.logger.With(zap.String("Path", upload.Path)).With(zap.String("Destination", upload.Destination))
+
+		destination, ok := b.destination(upload.Destination)
+
+		if !ok {
+			logger.Warn("Destination is no longer configured, upload dropped")
+			b.dequeue(upload)
+			success++
+			continue
+		}
+
+		if _, err := os.Stat(upload.Path); errors.Is(err, os.ErrNotExist) {
+			logger.Error("Local backup is missing, upload dropped")
+			b.dequeue(upload)
+			continue
+		}
+
+		if offline[upload.Destination] || b.running(upload.Job) {
+			continue
+		}
+
+		duration, err := b.upload(destination, upload.Path, b.remoteName(b.job(upload.Job), destination, upload.Path))
+
+		b.recordUpload(upload.Path, upload.Destination, err)
+
+		upload = b.confirm(upload, err)
+
+		if err != nil {
+			offline[upload.Destination] = true
+
+			logger.With(zap.Int("attempts", upload.Attempts)).With(zap.Error(err)).Warn("Pending upload failed")
+			continue
+		}
+
+		success++
+
+		logger.With(zap.Duration("duration", duration)).
+			With(zap.Duration("delay", time.Since(upload.Created))).
+			Info("Pending upload confirmed")
+	}
+
+	return newStatus(success, len(uploads))
+}
+
+func (b *BackupService) dequeue(upload models.Upload) {
+	if err := b.queue.Remove(upload.Path, upload.Destination); err != nil {
+		b.logger.With(zap.String("Path", upload.Path)).With(zap.Error(err)).Error("unable to update upload queue")
+	}
+}
+
+func (b *BackupService) destination(name string) (Destination, bool) {
+	for _, destination := range b.destinations {
+		if destination.Name == name {
+			return destination, true
+		}
+	}
+
+	return Destination{}, false
+}
@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"yd_backup/internal/repo/queue"
+)
+
+func TestUploadInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "queue.json")
+	crashed := filepath.Join(dir, "crashed.json")
+
+	// Журнал на момент прерывания: процесс умирает во время загрузки, и на
+	// диске остается только то, что было записано до нее
+	remote := &fakeRemote{err: errors.New("connection reset")}
+	remote.onUpload = func(string) {
+		data, err := os.ReadFile(path)
+
+		if err != nil {
+			t.Errorf("journal is not written before upload: %v", err)
+			return
+		}
+
+		os.WriteFile(crashed, data, 0600)
+	}
+
+	service := newTestService(t, testDestination("disk", remote))
+	service.SetQueue(queue.NewJournal(path))
+
+	outcome := service.Backup(service.setting.Files[0])
+
+	journal := queue.NewJournal(crashed)
+
+	uploads, err := journal.Pending()
+
+	if err != nil || len(uploads) != 1 {
+		t.Fatalf("journal at crash = %+v, %v, want upload in progress", uploads, err)
+	}
+
+	backupPath := uploads[0].Path
+
+	if uploads[0].Destination != "disk" || uploads[0].Job != "Trade" || uploads[0].Attempts != 0 {
+		t.Errorf("upload = %+v, want first attempt of Trade to disk", uploads[0])
+	}
+
+	// Локальная очистка не удаляет неподтвержденную копию
+	if !journal.IsPending(backupPath) {
+		t.Errorf("backup %s is not pending at crash", backupPath)
+	}
+
+	// Неудачная загрузка остается в журнале с ошибкой
+	uploads, _ = queue.NewJournal(path).Pending()
+
+	if outcome.Err == nil {
+		t.Errorf("outcome = %+v, want failed job", outcome)
+	}
+
+	if len(uploads) != 1 || uploads[0].Attempts != 1 || uploads[0].LastError != "connection reset" {
+		t.Errorf("journal after failure = %+v, want failed attempt", uploads)
+	}
+
+	// После перезапуска загрузка повторяется и удаляется из журнала
+	restarted := &fakeRemote{}
+
+	service = newTestService(t, testDestination("disk", restarted))
+	service.SetQueue(journal)
+
+	if status := service.RetryUploads(); status != StatusSuccess {
+		t.Errorf("status = %v, want success", status)
+	}
+
+	if names := restarted.uploads(); len(names) != 1 || names[0] != "Trade_20260101_120000" {
+		t.Errorf("uploads = %v, want interrupted backup", names)
+	}
+
+	if uploads, _ := journal.Pending(); len(uploads) != 0 || journal.IsPending(backupPath) {
+		t.Errorf("journal = %+v, want confirmed upload removed", uploads)
+	}
+}
+
+func TestUploadConfirmed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+
+	remote := &fakeRemote{}
+
+	service := newTestService(t, testDestination("disk", remote))
+	service.SetQueue(queue.NewJournal(path))
+
+	if outcome := service.Backup(service.setting.Files[0]); outcome.Err != nil {
+		t.Fatalf("Backup: %v", outcome.Err)
+	}
+
+	if uploads, _ := queue.NewJournal(path).Pending(); len(uploads) != 0 {
+		t.Errorf("journal = %+v, want empty after upload", uploads)
+	}
+}
@@ -0,0 +1,296 @@
+package usecase
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"sort"
+	"yd_backup/internal/models"
+)
+
+// SpaceReclaimer - назначение, в котором можно освободить место: удалить
+// отдельную копию или очистить корзину от файлов своей папки
+type SpaceReclaimer interface {
+	RemoveFile(path string) error
+	EmptyTrash() (int64, error)
+}
+
+// accountSpace - место аккаунта Yandex Disk и загрузки, планируемые в его
+// назначения
+type accountSpace struct {
+	account      string
+	quota        models.Quota
+	planned      int64
+	destinations []Destination
+}
+
+func (s *accountSpace) free() int64 {
+	return s.quota.Total - s.quota.Used
+}
+
+// checkQuota запрашивает место на дисках аккаунтов перед запуском заданий
+// files, предупреждает о заполнении и, если разрешено настройками,
+// освобождает место под планируемые загрузки. Запуск продолжается, даже
+// если места так и не хватило
+func (b *BackupService) checkQuota(files []models.Files) {
+	for _, space := range b.plannedSpace(files) {
+		logger := b.logger.With(zap.String("Account", space.account))
+
+		quota, err := space.destinations[0].Remote.(QuotaReporter).Quota()
+
+		if err != nil {
+			logger.With(zap.Error(err)).Error("unable to get disk quota")
+			continue
+		}
+
+		space.quota = quota
+
+		b.warnUsage(space)
+
+		if space.planned <= space.free() {
+			continue
+		}
+
+		logger.With(zap.Int64("planned", space.planned)).With(zap.Int64("free", space.free())).
+			Warn("Planned uploads do not fit on disk")
+
+		if b.setting.Quota.EmptyTrash {
+			b.emptyTrash(space)
+		}
+
+		if b.setting.Quota.Prune && space.planned > space.free() {
+			b.pruneForSpace(space)
+		}
+
+		if space.planned > space.free() {
+			logger.With(zap.Int64("planned", space.planned)).With(zap.Int64("free", space.free())).
+				Error("Not enough space on disk, uploads may fail")
+		}
+	}
+}
+
+// plannedSpace суммирует размеры исходных файлов заданий и ожидающих
+// загрузок по аккаунтам назначений, которые сообщают квоту
+func (b *BackupService) plannedSpace(files []models.Files) []*accountSpace {
+	var result []*accountSpace
+
+	spaces := make(map[string]*accountSpace)
+
+	add := func(destination Destination, size int64) {
+		if _, ok := destination.Remote.(QuotaReporter); !ok {
+			return
+		}
+
+		space, ok := spaces[destination.Account]
+
+		if !ok {
+			space = &accountSpace{account: destination.Account}
+			spaces[destination.Account] = space
+			result = append(result, space)
+		}
+
+		space.planned += size
+
+		for _, known := range space.destinations {
+			if known.Name == destination.Name {
+				return
+			}
+		}
+
+		space.destinations = append(space.destinations, destination)
+	}
+
+	for _, job := range files {
+		destinations, err := b.jobDestinations(job)
+
+		if err != nil {
+			continue
+		}
+
+		var size int64
+
+		if info, err := os.Stat(job.Path); err == nil {
+			size = info.Size()
+		}
+
+		for _, destination := range destinations {
+			add(destination, size)
+		}
+	}
+
+	if b.queue != nil {
+		uploads, err := b.queue.Pending()
+
+		if err != nil {
+			b.logger.With(zap.Error(err)).Error("unable to read upload queue")
+		}
+
+		for _, upload := range uploads {
+			info, err := os.Stat(upload.Path)
+
+			if err != nil {
+				continue
+			}
+
+			if destination, ok := b.destination(upload.Destination); ok {
+				add(destination, info.Size())
+			}
+		}
+	}
+
+	return result
+}
+
+// warnUsage предупреждает о самом высоком достигнутом пороге заполнения
+func (b *BackupService) warnUsage(space *accountSpace) {
+	if space.quota.Total == 0 {
+		return
+	}
+
+	usage := float64(space.quota.Used) / float64(space.quota.Total)
+
+	var reached float64
+
+	for _, threshold := range b.setting.Quota.Warn {
+		if usage >= threshold && threshold > reached {
+			reached = threshold
+		}
+	}
+
+	if reached == 0 {
+		return
+	}
+
+	b.logger.With(zap.String("Account", space.account)).
+		With(zap.String("usage", fmt.Sprintf("%.1f%%", usage*100))).
+		With(zap.String("threshold", fmt.Sprintf("%.0f%%", reached*100))).
+		With(zap.Int64("trash", space.quota.Trash)).
+		Warn("Disk usage is above threshold")
+}
+
+// emptyTrash удаляет из корзины файлы папок назначений аккаунта
+func (b *BackupService) emptyTrash(space *accountSpace) {
+	for _, destination := range space.destinations {
+		reclaimer, ok := destination.Remote.(SpaceReclaimer)
+
+		if !ok {
+			continue
+		}
+
+		freed, err := reclaimer.EmptyTrash()
+
+		space.quota.Used -= freed
+
+		if err != nil {
+			b.logger.With(zap.String("Destination", destination.Name)).With(zap.Error(err)).
+				Error("unable to empty trash")
+			continue
+		}
+
+		b.logger.With(zap.String("Destination", destination.Name)).With(zap.Int64("freed", freed)).
+			Info("Trash emptied")
+	}
+}
+
+// removable - копия в назначении, которую можно удалить ради места
+type removable struct {
+	destination Destination
+	file        models.BackupFile
+}
+
+// pruneForSpace удаляет самые старые копии в назначениях аккаунта, пока
+// планируемые загрузки не поместятся. Последние count копий каждого задания
+// в каждом назначении и защищенные копии не удаляются
+func (b *BackupService) pruneForSpace(space *accountSpace) {
+	unlock, err := b.lock("prune")
+	if err != nil {
+		b.logger.With(zap.Error(err)).Info("Prune is already running in another process, skipped")
+		return
+	}
+	defer unlock()
+
+	b.protect()
+
+	var candidates []removable
+
+	for _, destination := range space.destinations {
+		if _, ok := destination.Remote.(SpaceReclaimer); !ok {
+			continue
+		}
+
+		files, err := destination.Remote.ListBackup()
+
+		if err != nil {
+			b.logger.With(zap.String("Destination", destination.Name)).With(zap.Error(err)).
+				Error("unable to list backups")
+			continue
+		}
+
+		candidates = append(candidates, b.prunable(destination, files)...)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return fileCreated(candidates[i].file).Before(fileCreated(candidates[j].file))
+	})
+
+	removed := make(map[string][]string)
+
+	for _, candidate := range candidates {
+		if space.planned <= space.free() {
+			break
+		}
+
+		logger := b.logger.With(zap.String("Destination", candidate.destination.Name)).
+			With(zap.String("Path", candidate.file.Path))
+
+		if err := candidate.destination.Remote.(SpaceReclaimer).RemoveFile(candidate.file.Path); err != nil {
+			logger.With(zap.Error(err)).Error("unable to remove backup")
+			continue
+		}
+
+		logger.With(zap.Int64("size", candidate.file.Size)).Info("Backup removed to free space")
+
+		space.quota.Used -= candidate.file.Size
+		removed[candidate.destination.Name] = append(removed[candidate.destination.Name], candidate.file.Path)
+	}
+
+	for destination, paths := range removed {
+		b.recordRemoved(destination, paths)
+		b.observeRetention(destination, paths)
+	}
+}
+
+// prunable возвращает копии назначения, которые можно удалить: кроме
+// последних count копий каждого задания, закрепленных и защищенных копий и
+// файлов, не похожих на копии
+func (b *BackupService) prunable(destination Destination, files []models.BackupFile) []removable {
+	jobs := make(map[string][]models.BackupFile)
+
+	for _, file := range files {
+		job, ok := fileJob(file)
+
+		if !ok || file.Pinned || b.IsProtected(file.Path) {
+			continue
+		}
+
+		jobs[job] = append(jobs[job], file)
+	}
+
+	var result []removable
+
+	for _, jobFiles := range jobs {
+		sort.Slice(jobFiles, func(i, j int) bool {
+			return fileCreated(jobFiles[i]).After(fileCreated(jobFiles[j]))
+		})
+
+		if len(jobFiles) <= b.setting.Backup.Retention {
+			continue
+		}
+
+		for _, file := range jobFiles[b.setting.Backup.Retention:] {
+			result = append(result, removable{destination: destination, file: file})
+		}
+	}
+
+	return result
+}
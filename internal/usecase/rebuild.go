@@ -0,0 +1,483 @@
+package usecase
+
+import (
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"yd_backup/internal/models"
+)
+
+const (
+	ReconcileOrphan       = "orphan"
+	ReconcileMissing      = "missing"
+	ReconcileSizeInvalid  = "size mismatch"
+	ReconcileHashInvalid  = "md5 mismatch"
+	backupTimestampLayout = "20060102150405"
+)
+
+// backupName - схема имени копии <задание>_<время>_<исходный файл>
+var backupName = regexp.MustCompile(`^(.+)_(\d{14})_(.+)$`)
+
+// Discrepancy - расхождение каталога с назначением
+type Discrepancy struct {
+	Destination string `json:"destination"`
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	Detail      string `json:"detail,omitempty"`
+}
+
+// RebuildCatalog восстанавливает каталог по назначениям: для каждой копии в
+// папке назначения берется запись из загруженной туда копии каталога, а
+// если ее нет - запись собирается из описания копии, записанного при
+// загрузке, или из имени файла. Локальные копии, которые еще лежат в
+// backup.dir, привязываются к записям, а копии, которых нет ни в одном
+// назначении, и загрузки из журнала попадают в каталог как ожидающие
+func (b *BackupService) RebuildCatalog() ([]models.CatalogEntry, Status) {
+	if b.catalog == nil {
+		b.logger.Error("catalog is not configured")
+		return nil, StatusFailed
+	}
+
+	known := make(map[string]models.CatalogEntry)
+
+	for _, destination := range b.destinations {
+		for _, entry := range b.downloadCatalog(destination) {
+			if _, ok := known[entry.ID]; !ok {
+				known[entry.ID] = entry
+			}
+		}
+	}
+
+	// Прежний каталог, если он читается, дополняет копии каталога из
+	// назначений записями, которые туда еще не загружены
+	if previous, err := b.catalog.Entries(); err == nil {
+		for _, entry := range previous {
+			if _, ok := known[entry.ID]; !ok {
+				known[entry.ID] = entry
+			}
+		}
+	} else {
+		b.logger.With(zap.Error(err)).Warn("unable to read catalog, it is rebuilt from destinations only")
+	}
+
+	localFiles := make(map[string]models.BackupFile)
+
+	if files, err := b.local.ListBackup(); err == nil {
+		for _, file := range files {
+			localFiles[file.Name] = file
+		}
+	} else {
+		b.logger.With(zap.Error(err)).Error("unable to list local backups")
+	}
+
+	remoteFiles := b.remoteFiles()
+
+	entries := make(map[string]*models.CatalogEntry)
+
+	for _, destination := range b.destinations {
+		files, ok := remoteFiles[destination.Name]
+
+		if !ok {
+			continue
+		}
+
+		for _, file := range files {
+			entry := b.rebuildEntry(entries, known, destination.Name, file)
+
+			if file.Pinned {
+				entry.Pinned = true
+			}
+
+			entry.Copies = append(entry.Copies, models.CatalogCopy{
+				Destination: destination.Name,
+				Name:        file.Name,
+				RemotePath:  file.Path,
+				Status:      models.CopyUploaded,
+				Uploaded:    file.Created,
+			})
+		}
+	}
+
+	for _, file := range localFiles {
+		if findEntry(entries, file.Name) == nil {
+			b.localEntry(entries, known, file)
+		}
+	}
+
+	b.rebuildPending(entries, known, remoteFiles)
+
+	var result []models.CatalogEntry
+
+	for _, entry := range entries {
+		if local, ok := localFiles[entry.ID]; ok {
+			entry.LocalPath = local.Path
+		}
+
+		sort.Slice(entry.Copies, func(i, j int) bool {
+			return entry.Copies[i].Destination < entry.Copies[j].Destination
+		})
+
+		result = append(result, *entry)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Created.Before(result[j].Created)
+	})
+
+	if err := b.catalog.Replace(result); err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to write catalog")
+		return result, StatusFailed
+	}
+
+	b.logger.With(zap.Int("entries", len(result))).With(zap.Int("from_catalog", len(known))).
+		Info("Catalog rebuilt")
+
+	return result, newStatus(len(remoteFiles), len(b.destinations))
+}
+
+// rebuildEntry находит или создает запись для копии file в назначении
+func (b *BackupService) rebuildEntry(entries map[string]*models.CatalogEntry, known map[string]models.CatalogEntry,
+	destination string, file models.BackupFile) *models.CatalogEntry {
+
+	for id, entry := range known {
+		if catalogCopy := entry.Copy(destination); catalogCopy != nil && catalogCopy.RemoteName() == file.Name {
+			if _, ok := entries[id]; !ok {
+				entry.Copies = nil
+				entry.LocalPath = ""
+				entries[id] = &entry
+			}
+
+			return entries[id]
+		}
+	}
+
+	// Назначения без extension хранят копию без расширения, поэтому записи
+	// из разных назначений сводятся по имени без расширения. Имя файла по
+	// шаблону пути, например день недели, повторяется в разных папках, и
+	// копия без описания сводится по пути относительно папки назначения
+	name := path.Base(file.Name)
+
+	switch {
+	case file.Metadata != nil:
+		name = file.Metadata.ID
+	case !backupName.MatchString(name):
+		name = file.Name
+	}
+
+	key := strings.TrimSuffix(name, filepath.Ext(name))
+
+	for _, entry := range entries {
+		if strings.TrimSuffix(entry.ID, filepath.Ext(entry.ID)) == key {
+			if len(name) > len(entry.ID) {
+				entry.ID = name
+			}
+
+			if file.Metadata != nil {
+				applyMetadata(entry, *file.Metadata)
+			}
+
+			return entry
+		}
+	}
+
+	if file.Metadata != nil {
+		entry := &models.CatalogEntry{}
+
+		applyMetadata(entry, *file.Metadata)
+
+		entries[entry.ID] = entry
+
+		return entry
+	}
+
+	entry := nameEntry(name, file)
+
+	entries[name] = entry
+
+	return entry
+}
+
+// nameEntry собирает запись по имени копии <задание>_<время>_<исходный
+// файл>, размер и md5 берутся из описания файла
+func nameEntry(name string, file models.BackupFile) *models.CatalogEntry {
+	entry := &models.CatalogEntry{
+		ID:          name,
+		Created:     file.Created,
+		Size:        file.Size,
+		Md5:         file.Md5,
+		Compression: models.CompressionNone,
+		Encryption:  models.EncryptionNone,
+	}
+
+	if match := backupName.FindStringSubmatch(name); match != nil {
+		entry.Job = match[1]
+
+		if created, err := time.ParseInLocation(backupTimestampLayout, match[2], time.Local); err == nil {
+			entry.Started = created
+			entry.Created = created
+		}
+	}
+
+	return entry
+}
+
+// findEntry находит запись копии id, в том числе сведенную по имени без
+// расширения
+func findEntry(entries map[string]*models.CatalogEntry, id string) *models.CatalogEntry {
+	if entry, ok := entries[id]; ok {
+		return entry
+	}
+
+	key := strings.TrimSuffix(id, filepath.Ext(id))
+
+	for _, entry := range entries {
+		if strings.TrimSuffix(entry.ID, filepath.Ext(entry.ID)) == key {
+			return entry
+		}
+	}
+
+	return nil
+}
+
+// localEntry добавляет запись для копии, которая есть только в backup.dir:
+// из каталога, а если копии в нем нет - по имени и содержимому файла.
+// Файлы, не похожие на копии, пропускаются
+func (b *BackupService) localEntry(entries map[string]*models.CatalogEntry, known map[string]models.CatalogEntry,
+	file models.BackupFile) *models.CatalogEntry {
+
+	if entry, ok := known[file.Name]; ok {
+		entry.Copies = nil
+		entry.LocalPath = file.Path
+		entries[entry.ID] = &entry
+
+		return &entry
+	}
+
+	if !backupName.MatchString(file.Name) {
+		return nil
+	}
+
+	entry := nameEntry(file.Name, file)
+	entry.LocalPath = file.Path
+
+	md5Sum, sha256Sum, err := fileHashes(file.Path)
+
+	if err != nil {
+		b.logger.With(zap.String("Path", file.Path)).With(zap.Error(err)).Error("unable to hash backup")
+	}
+
+	entry.Md5 = md5Sum
+	entry.Sha256 = sha256Sum
+
+	entries[entry.ID] = entry
+
+	return entry
+}
+
+// rebuildPending сводит журнал загрузок с назначениями: загрузка, копия
+// которой уже лежит в назначении целиком, была прервана до подтверждения и
+// удаляется из журнала, остальные попадают в каталог ожидающими копиями
+func (b *BackupService) rebuildPending(entries map[string]*models.CatalogEntry, known map[string]models.CatalogEntry,
+	remoteFiles map[string]map[string]models.BackupFile) {
+
+	if b.queue == nil {
+		return
+	}
+
+	uploads, err := b.queue.Pending()
+
+	if err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to read upload queue")
+		return
+	}
+
+	for _, upload := range uploads {
+		destination, ok := b.destination(upload.Destination)
+
+		if !ok {
+			continue
+		}
+
+		info, err := os.Stat(upload.Path)
+
+		if err != nil {
+			continue
+		}
+
+		id := filepath.Base(upload.Path)
+
+		entry := findEntry(entries, id)
+
+		if entry == nil {
+			entry = b.localEntry(entries, known, models.BackupFile{
+				Name:    id,
+				Path:    upload.Path,
+				Size:    info.Size(),
+				Created: info.ModTime(),
+			})
+		}
+
+		if entry == nil {
+			continue
+		}
+
+		catalogCopy := entry.Copy(upload.Destination)
+
+		if catalogCopy != nil {
+			if file, ok := remoteFiles[upload.Destination][catalogCopy.RemoteName()]; ok && file.Size == info.Size() {
+				b.logger.With(zap.String("Path", upload.Path)).With(zap.String("Destination", upload.Destination)).
+					Info("Pending upload is already in destination, removed from queue")
+
+				b.dequeue(upload)
+				continue
+			}
+
+			catalogCopy.Status = models.CopyPending
+			catalogCopy.Error = upload.LastError
+			continue
+		}
+
+		name := b.remoteName(b.job(upload.Job), destination, upload.Path)
+
+		entry.Copies = append(entry.Copies, models.CatalogCopy{
+			Destination: upload.Destination,
+			Name:        name,
+			RemotePath:  destination.Remote.RemotePath(name),
+			Status:      models.CopyPending,
+			Error:       upload.LastError,
+		})
+	}
+}
+
+// applyMetadata заполняет запись, собранную без каталога, по описанию копии
+func applyMetadata(entry *models.CatalogEntry, metadata models.BackupMetadata) {
+	entry.ID = metadata.ID
+	entry.Job = metadata.Job
+	entry.Source = metadata.Source
+	entry.Started = metadata.Created
+	entry.Created = metadata.Created
+	entry.Size = metadata.Size
+	entry.Md5 = metadata.Md5
+	entry.Sha256 = metadata.Sha256
+	entry.Compression = metadata.Compression
+	entry.Encryption = metadata.Encryption
+}
+
+func (b *BackupService) downloadCatalog(destination Destination) []models.CatalogEntry {
+	logger := b.logger.With(zap.String("Destination", destination.Name))
+
+	data, err := destination.Remote.DownloadCatalog()
+
+	if err != nil {
+		logger.With(zap.Error(err)).Warn("unable to download catalog copy")
+		return nil
+	}
+
+	if data == nil {
+		logger.Info("Destination has no catalog copy, entries are rebuilt from file names")
+		return nil
+	}
+
+	var entries []models.CatalogEntry
+
+	if err := json.Unmarshal(data, &entries); err != nil {
+		logger.With(zap.Error(err)).Warn("unable to parse catalog copy")
+		return nil
+	}
+
+	return entries
+}
+
+// Reconcile сравнивает каталог с назначениями и возвращает расхождения:
+// файлы в назначениях без записи в каталоге, загруженные по каталогу копии,
+// которых нет в назначении, и копии с другим размером или md5
+func (b *BackupService) Reconcile() ([]Discrepancy, Status) {
+	if b.catalog == nil {
+		b.logger.Error("catalog is not configured")
+		return nil, StatusFailed
+	}
+
+	entries, err := b.catalog.Entries()
+
+	if err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to read catalog")
+		return nil, StatusFailed
+	}
+
+	remoteFiles := b.remoteFiles()
+
+	result := []Discrepancy{}
+
+	for _, destination := range b.destinations {
+		files, ok := remoteFiles[destination.Name]
+
+		if !ok {
+			continue
+		}
+
+		cataloged := make(map[string]bool)
+
+		for _, entry := range entries {
+			catalogCopy := entry.Copy(destination.Name)
+
+			if catalogCopy == nil {
+				continue
+			}
+
+			name := catalogCopy.RemoteName()
+			file, exists := files[name]
+
+			cataloged[name] = true
+
+			switch {
+			case catalogCopy.Status != models.CopyUploaded:
+			case !exists:
+				result = append(result, Discrepancy{Destination: destination.Name, Name: name, Kind: ReconcileMissing})
+			case file.Size != entry.Size:
+				result = append(result, Discrepancy{Destination: destination.Name, Name: name,
+					Kind: ReconcileSizeInvalid, Detail: fmt.Sprintf("%d, expected %d", file.Size, entry.Size)})
+			case file.Md5 != "" && entry.Md5 != "" && file.Md5 != entry.Md5:
+				result = append(result, Discrepancy{Destination: destination.Name, Name: name,
+					Kind: ReconcileHashInvalid, Detail: fmt.Sprintf("%s, expected %s", file.Md5, entry.Md5)})
+			}
+		}
+
+		for name := range files {
+			if !cataloged[name] {
+				result = append(result, Discrepancy{Destination: destination.Name, Name: name, Kind: ReconcileOrphan})
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Destination != result[j].Destination {
+			return result[i].Destination < result[j].Destination
+		}
+
+		return result[i].Name < result[j].Name
+	})
+
+	for _, discrepancy := range result {
+		b.logger.With(zap.String("Destination", discrepancy.Destination)).
+			With(zap.String("Name", discrepancy.Name)).
+			With(zap.String("kind", discrepancy.Kind)).
+			Warn("Catalog discrepancy")
+	}
+
+	if len(remoteFiles) == 0 && len(b.destinations) > 0 {
+		return result, StatusFailed
+	}
+
+	if len(result) > 0 || len(remoteFiles) < len(b.destinations) {
+		return result, StatusPartial
+	}
+
+	return result, StatusSuccess
+}
@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"yd_backup/internal/models"
+	"yd_backup/internal/repo/catalog"
+	"yd_backup/internal/repo/queue"
+)
+
+func TestRebuildCatalogKeepsLocalAndPending(t *testing.T) {
+	const (
+		localOnly   = "Trade_20260101120000_trade.db"
+		pending     = "Trade_20260102120000_trade.db"
+		interrupted = "Trade_20260103120000_trade.db"
+	)
+
+	remote := &fakeRemote{}
+
+	service := newTestService(t, testDestination("disk", remote))
+
+	dir := service.local.(*fakeLocal).dir
+
+	for _, name := range []string{localOnly, pending, interrupted, "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("backup "+name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Загрузка interrupted завершилась, но процесс умер до подтверждения
+	remote.files = []models.BackupFile{{
+		Name:    interrupted,
+		Path:    interrupted,
+		Size:    int64(len("backup " + interrupted)),
+		Created: time.Now(),
+	}}
+
+	journal := queue.NewJournal(filepath.Join(t.TempDir(), "queue.json"))
+
+	for _, name := range []string{pending, interrupted} {
+		journal.Add(models.Upload{
+			Path:        filepath.Join(dir, name),
+			Job:         "Trade",
+			Destination: "disk",
+			LastError:   "connection reset",
+		})
+	}
+
+	service.SetQueue(journal)
+	service.SetCatalog(catalog.NewCatalog(filepath.Join(t.TempDir(), "catalog.json")))
+
+	entries, _ := service.RebuildCatalog()
+
+	found := make(map[string]models.CatalogEntry)
+
+	for _, entry := range entries {
+		found[entry.ID] = entry
+	}
+
+	if len(found) != 3 {
+		t.Fatalf("entries = %+v, want local, pending and interrupted backups", entries)
+	}
+
+	// Копия, которой нет ни в одном назначении, остается в каталоге
+	if entry := found[localOnly]; entry.LocalPath == "" || entry.Job != "Trade" || entry.Md5 == "" || len(entry.Copies) != 0 {
+		t.Errorf("local entry = %+v, want local copy without destinations", entry)
+	}
+
+	// Загрузка из журнала попадает в каталог ожидающей копией
+	entry := found[pending]
+
+	if catalogCopy := entry.Copy("disk"); catalogCopy == nil || catalogCopy.Status != models.CopyPending ||
+		catalogCopy.Error != "connection reset" || entry.LocalPath == "" {
+		t.Errorf("pending entry = %+v, want pending copy in disk", entry)
+	}
+
+	// Загрузка, копия которой уже в назначении, удаляется из журнала
+	entry = found[interrupted]
+
+	if catalogCopy := entry.Copy("disk"); catalogCopy == nil || catalogCopy.Status != models.CopyUploaded {
+		t.Errorf("interrupted entry = %+v, want uploaded copy in disk", entry)
+	}
+
+	uploads, _ := journal.Pending()
+
+	if len(uploads) != 1 || filepath.Base(uploads[0].Path) != pending {
+		t.Errorf("journal = %+v, want only pending upload", uploads)
+	}
+}
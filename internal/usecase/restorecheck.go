@@ -0,0 +1,258 @@
+package usecase
+
+import (
+	"bytes"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"yd_backup/internal/models"
+)
+
+const (
+	RestoreOk             = "ok"
+	RestoreDownloadFailed = "download failed"
+	RestoreUnsupported    = "unsupported format"
+	RestoreHashInvalid    = "hash mismatch"
+	RestoreHeaderInvalid  = "invalid 1CD header"
+)
+
+// Сигнатура в начале файла информационной базы 1С
+var header1CD = []byte("1CDBMV8")
+
+// RestoreCheck - результат проверки одной копии восстановлением
+type RestoreCheck struct {
+	Destination string        `json:"destination"`
+	Name        string        `json:"name"`
+	Result      string        `json:"result"`
+	Detail      string        `json:"detail,omitempty"`
+	Duration    time.Duration `json:"duration"`
+}
+
+// TestRestore скачивает count копий из каталога во временную директорию,
+// распаковывает их и сверяет хеш с каталогом, а для баз 1С проверяет
+// заголовок 1CD. Берутся копии, которые дольше всех не проверялись
+// восстановлением, или случайные при random. Каждая загруженная копия
+// записи проверяется во всех назначениях, итог записывается в каталог
+func (b *BackupService) TestRestore(count int, random bool) ([]RestoreCheck, Status) {
+	if b.catalog == nil {
+		b.logger.Error("catalog is not configured")
+		return nil, StatusFailed
+	}
+
+	entries, err := b.catalog.Entries()
+
+	if err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to read catalog")
+		return nil, StatusFailed
+	}
+
+	entries = restoreCandidates(entries, random)
+
+	if len(entries) > count {
+		entries = entries[:count]
+	}
+
+	if len(entries) == 0 {
+		b.logger.Info("No uploaded backups to test")
+		return nil, StatusSuccess
+	}
+
+	tempDir, err := os.MkdirTemp("", "yd_backup_restore")
+
+	if err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to create temp dir")
+		return nil, StatusFailed
+	}
+
+	defer os.RemoveAll(tempDir)
+
+	started := time.Now()
+
+	var result []RestoreCheck
+
+	success := 0
+
+	for _, entry := range entries {
+		restored := RestoreOk
+
+		for _, catalogCopy := range entry.Copies {
+			destination, ok := b.destination(catalogCopy.Destination)
+
+			if !ok || catalogCopy.Status != models.CopyUploaded {
+				continue
+			}
+
+			check := b.testRestore(entry, catalogCopy, destination, tempDir)
+
+			logger := b.logger.With(zap.String("Destination", check.Destination)).
+				With(zap.String("Name", check.Name)).
+				With(zap.Duration("duration", check.Duration))
+
+			if check.Result == RestoreOk {
+				success++
+				logger.Info("Test restore succeeded")
+			} else {
+				restored = check.Result
+				logger.With(zap.String("result", check.Result)).With(zap.String("detail", check.Detail)).
+					Error("Test restore failed")
+			}
+
+			result = append(result, check)
+		}
+
+		b.updateCatalog(entry.ID, func(entry *models.CatalogEntry) {
+			entry.Restored = restored
+			entry.RestoredAt = time.Now()
+		})
+	}
+
+	b.publishCatalog()
+
+	status := newStatus(success, len(result))
+
+	b.notifyTestRestore(result, started, status)
+
+	return result, status
+}
+
+// restoreCandidates оставляет записи с загруженными копиями: сначала не
+// проверявшиеся, затем проверявшиеся давно. При random порядок случайный
+func restoreCandidates(entries []models.CatalogEntry, random bool) []models.CatalogEntry {
+	var result []models.CatalogEntry
+
+	for _, entry := range entries {
+		for _, catalogCopy := range entry.Copies {
+			if catalogCopy.Status == models.CopyUploaded {
+				result = append(result, entry)
+				break
+			}
+		}
+	}
+
+	if random {
+		rand.Shuffle(len(result), func(i, j int) {
+			result[i], result[j] = result[j], result[i]
+		})
+
+		return result
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].RestoredAt.Before(result[j].RestoredAt)
+	})
+
+	return result
+}
+
+func (b *BackupService) testRestore(entry models.CatalogEntry, catalogCopy models.CatalogCopy, destination Destination,
+	tempDir string) (check RestoreCheck) {
+
+	started := time.Now()
+
+	check = RestoreCheck{
+		Destination: catalogCopy.Destination,
+		Name:        entry.ID,
+		Result:      RestoreOk,
+	}
+
+	defer func() {
+		check.Duration = time.Since(started)
+	}()
+
+	downloadPath := filepath.Join(tempDir, catalogCopy.Destination+"_"+entry.ID)
+
+	defer os.Remove(downloadPath)
+
+	if err := destination.Remote.DownloadBackup(catalogCopy.RemoteName(), downloadPath); err != nil {
+		check.Result, check.Detail = RestoreDownloadFailed, err.Error()
+		return check
+	}
+
+	restoredPath, err := unpack(entry, downloadPath)
+
+	if err != nil {
+		check.Result, check.Detail = RestoreUnsupported, err.Error()
+		return check
+	}
+
+	md5Sum, sha256Sum, err := fileHashes(restoredPath)
+
+	switch {
+	case err != nil:
+		check.Result, check.Detail = RestoreDownloadFailed, err.Error()
+		return check
+	case entry.Sha256 != "" && sha256Sum != entry.Sha256:
+		check.Result, check.Detail = RestoreHashInvalid, fmt.Sprintf("sha256 %s, expected %s", sha256Sum, entry.Sha256)
+		return check
+	case entry.Md5 != "" && md5Sum != entry.Md5:
+		check.Result, check.Detail = RestoreHashInvalid, fmt.Sprintf("md5 %s, expected %s", md5Sum, entry.Md5)
+		return check
+	}
+
+	if is1CD(entry) {
+		if err := check1CDHeader(restoredPath); err != nil {
+			check.Result, check.Detail = RestoreHeaderInvalid, err.Error()
+		}
+	}
+
+	return check
+}
+
+// unpack расшифровывает и распаковывает скачанную копию и возвращает путь
+// к восстановленному файлу. Сейчас копии хранятся как есть
+func unpack(entry models.CatalogEntry, path string) (string, error) {
+	if entry.Encryption != "" && entry.Encryption != models.EncryptionNone {
+		return "", fmt.Errorf("unsupported encryption %s", entry.Encryption)
+	}
+
+	if entry.Compression != "" && entry.Compression != models.CompressionNone {
+		return "", fmt.Errorf("unsupported compression %s", entry.Compression)
+	}
+
+	return path, nil
+}
+
+// is1CD сообщает, что копия - файл информационной базы 1С
+func is1CD(entry models.CatalogEntry) bool {
+	name := entry.Source
+
+	if name == "" {
+		name = entry.ID
+	}
+
+	return strings.EqualFold(filepath.Ext(name), ".1cd")
+}
+
+// check1CDHeader проверяет сигнатуру 1CDBMV8 и версию формата 8.x в
+// заголовке файла базы
+func check1CDHeader(path string) error {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	header := make([]byte, len(header1CD)+2)
+
+	if _, err := io.ReadFull(file, header); err != nil {
+		return fmt.Errorf("file is too short: %v", err)
+	}
+
+	if !bytes.Equal(header[:len(header1CD)], header1CD) {
+		return fmt.Errorf("signature %q, expected %q", header[:len(header1CD)], header1CD)
+	}
+
+	if version := header[len(header1CD)+1]; version != 8 {
+		return fmt.Errorf("unsupported format version %d", version)
+	}
+
+	return nil
+}
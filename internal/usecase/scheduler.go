@@ -0,0 +1,312 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"math/rand"
+	"sync"
+	"time"
+	"yd_backup/internal/models"
+)
+
+type StateStore interface {
+	Get(job string) (models.JobState, bool)
+	Set(job string, state models.JobState) error
+}
+
+// pruneWait - наибольшее время, которое очистка ждет завершения текущих
+// запусков, если задания идут без перерыва
+const pruneWait = 30 * time.Minute
+
+// Scheduler запускает задания по их расписаниям в режиме демона. У каждого
+// задания свой цикл, поэтому два запуска одного задания не пересекаются
+type Scheduler struct {
+	service *BackupService
+	state   StateStore
+	logger  *zap.Logger
+	prune   chan struct{}
+	mu      sync.Mutex
+	running int
+}
+
+func NewScheduler(service *BackupService, state StateStore, logger *zap.Logger) *Scheduler {
+	return &Scheduler{
+		service: service,
+		state:   state,
+		logger:  logger,
+		prune:   make(chan struct{}, 1),
+	}
+}
+
+// Run запускает циклы всех заданий с расписанием и ждет их завершения
+// после отмены ctx. Текущие запуски при этом доводятся до конца
+func (s *Scheduler) Run(ctx context.Context) error {
+	wg := &sync.WaitGroup{}
+
+	count := 0
+
+	for _, files := range s.service.setting.Files {
+		if !files.IsScheduled() {
+			s.logger.With(zap.String("Job", files.Name)).Info("Job has no schedule and is not run by daemon")
+			continue
+		}
+
+		schedule, err := files.GetSchedule()
+
+		if err != nil {
+			return fmt.Errorf("invalid schedule of job %s: %v", files.Name, err)
+		}
+
+		count++
+
+		wg.Add(1)
+
+		go func(files models.Files) {
+			defer wg.Done()
+			s.loop(ctx, files, schedule)
+		}(files)
+	}
+
+	if count == 0 {
+		return fmt.Errorf("no jobs with schedule or interval")
+	}
+
+	if verify := s.service.setting.Verify; verify.IsScheduled() {
+		schedule, err := verify.GetSchedule()
+
+		if err != nil {
+			return fmt.Errorf("invalid verify schedule: %v", err)
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			s.verify(ctx, schedule)
+		}()
+	}
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		s.retry(ctx)
+	}()
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		s.watchdog(ctx)
+	}()
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		s.pruner(ctx)
+	}()
+
+	wg.Wait()
+
+	return nil
+}
+
+// retry периодически повторяет неподтвержденные загрузки
+func (s *Scheduler) retry(ctx context.Context) {
+	ticker := time.NewTicker(s.service.setting.Daemon.Retry.Duration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.service.RetryUploads()
+		}
+	}
+}
+
+// watchdog проверяет возраст последних успешных копий и токены аккаунтов
+// при старте демона и затем периодически
+func (s *Scheduler) watchdog(ctx context.Context) {
+	ticker := time.NewTicker(s.service.setting.Daemon.Watchdog.Duration)
+	defer ticker.Stop()
+
+	for {
+		s.service.CheckStale()
+		s.service.CheckCredentials()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pruner удаляет устаревшие копии один раз за проход расписания: запросы
+// завершившихся заданий объединяются, а очистка ждет, пока закончатся
+// остальные текущие запуски, но не дольше pruneWait
+func (s *Scheduler) pruner(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.prune:
+		}
+
+		deadline := time.Now().Add(pruneWait)
+
+		for s.busy() && time.Now().Before(deadline) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+
+		s.service.EraseBackup()
+	}
+}
+
+// requestPrune запрашивает очистку, если она еще не запрошена
+func (s *Scheduler) requestPrune() {
+	select {
+	case s.prune <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) busy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.running > 0
+}
+
+// verify запускает проверку восстановлением по расписанию
+func (s *Scheduler) verify(ctx context.Context, schedule models.Schedule) {
+	verify := s.service.setting.Verify
+
+	for {
+		next := schedule.Next(time.Now())
+
+		s.logger.With(zap.Time("next_run", next)).Info("Next test restore planned")
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.service.TestRestore(verify.Count, verify.Random)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, files models.Files, schedule models.Schedule) {
+	logger := s.logger.With(zap.String("Job", files.Name))
+
+	next := s.firstRun(files, schedule, time.Now())
+
+	for {
+		s.plan(files, next)
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		started := time.Now()
+
+		s.run(files)
+
+		finished := time.Now()
+
+		if schedule.Next(started).Before(finished) {
+			logger.With(zap.Duration("duration", finished.Sub(started))).
+				Warn("Run took longer than the schedule period, overlapping runs skipped")
+		}
+
+		next = withJitter(schedule.Next(finished), files.Jitter.Duration)
+	}
+}
+
+// firstRun выбирает время первого запуска после старта демона. Если по
+// расписанию запуск был пропущен, пока демон не работал, задание с
+// catch_up=run запускается сразу, а с catch_up=skip ждет следующего времени
+func (s *Scheduler) firstRun(files models.Files, schedule models.Schedule, now time.Time) time.Time {
+	logger := s.logger.With(zap.String("Job", files.Name))
+
+	state, ok := s.state.Get(files.Name)
+
+	if !ok || state.LastRun.IsZero() {
+		if files.Interval.Duration > 0 {
+			return now
+		}
+
+		return withJitter(schedule.Next(now), files.Jitter.Duration)
+	}
+
+	missed := schedule.Next(state.LastRun)
+
+	if !missed.Before(now) {
+		return withJitter(missed, files.Jitter.Duration)
+	}
+
+	if files.CatchUp == models.CatchUpRun {
+		logger.With(zap.Time("missed", missed)).Info("Missed run, catching up")
+		return now
+	}
+
+	logger.With(zap.Time("missed", missed)).Info("Missed run skipped")
+
+	return withJitter(schedule.Next(now), files.Jitter.Duration)
+}
+
+func (s *Scheduler) plan(files models.Files, next time.Time) {
+	s.logger.With(zap.String("Job", files.Name)).With(zap.Time("next_run", next)).Info("Next run planned")
+
+	state, _ := s.state.Get(files.Name)
+	state.NextRun = next
+
+	if err := s.state.Set(files.Name, state); err != nil {
+		s.logger.With(zap.Error(err)).Error("unable to save daemon state")
+	}
+}
+
+// run выполняет задание и запрашивает очистку. Итог задания записывает в
+// состояние сам BackupAll
+func (s *Scheduler) run(files models.Files) {
+	s.mu.Lock()
+	s.running++
+	s.mu.Unlock()
+
+	_, _, err := s.service.BackupAll([]string{files.Name})
+
+	s.mu.Lock()
+	s.running--
+	s.mu.Unlock()
+
+	if err != nil {
+		s.logger.With(zap.String("Job", files.Name)).With(zap.Error(err)).Error("Backup failed")
+	}
+
+	s.requestPrune()
+}
+
+func withJitter(t time.Time, jitter time.Duration) time.Time {
+	if jitter <= 0 {
+		return t
+	}
+
+	return t.Add(time.Duration(rand.Int63n(int64(jitter))))
+}
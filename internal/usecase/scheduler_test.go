@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+	"yd_backup/internal/models"
+)
+
+func TestSchedulerRunSavesStateOnce(t *testing.T) {
+	service := newTestService(t, testDestination("disk", &fakeRemote{}))
+
+	state := &memoryState{}
+	service.SetState(state)
+
+	scheduler := NewScheduler(service, state, service.logger)
+
+	scheduler.run(service.setting.Files[0])
+
+	jobState, _ := state.Get("Trade")
+
+	if jobState.LastStatus != StatusSuccess.String() || jobState.LastRun.IsZero() || jobState.LastSuccess.IsZero() {
+		t.Errorf("state = %+v, want successful run", jobState)
+	}
+
+	if state.sets != 1 {
+		t.Errorf("state writes = %d, want 1 by BackupAll", state.sets)
+	}
+
+	// Очистка запрошена, но выполняется не в самом запуске
+	if local := service.local.(*fakeLocal); local.erases() != 0 {
+		t.Errorf("erases = %d after run, want prune in pruner", local.erases())
+	}
+}
+
+func TestSchedulerPruneOncePerPass(t *testing.T) {
+	service := newTestService(t)
+	local := service.local.(*fakeLocal)
+
+	scheduler := NewScheduler(service, &memoryState{}, service.logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		scheduler.pruner(ctx)
+		close(done)
+	}()
+
+	// Три задания завершились, одно еще выполняется
+	scheduler.mu.Lock()
+	scheduler.running = 1
+	scheduler.mu.Unlock()
+
+	for i := 0; i < 3; i++ {
+		scheduler.requestPrune()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if local.erases() != 0 {
+		t.Fatalf("erases = %d while a job is running, want 0", local.erases())
+	}
+
+	scheduler.mu.Lock()
+	scheduler.running = 0
+	scheduler.mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for local.erases() == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if local.erases() != 1 {
+		t.Errorf("erases = %d, want one prune for the pass", local.erases())
+	}
+
+	cancel()
+	<-done
+}
+
+func TestSchedulerFirstRun(t *testing.T) {
+	service := newTestService(t)
+	state := &memoryState{}
+
+	scheduler := NewScheduler(service, state, service.logger)
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local)
+
+	files := models.Files{Name: "Trade", Schedule: "0 2 * * *", CatchUp: models.CatchUpRun}
+	schedule, _ := files.GetSchedule()
+
+	state.Set("Trade", models.JobState{LastRun: now.Add(-36 * time.Hour)})
+
+	if next := scheduler.firstRun(files, schedule, now); !next.Equal(now) {
+		t.Errorf("next = %s, want catch up now", next)
+	}
+
+	files.CatchUp = models.CatchUpSkip
+
+	if next := scheduler.firstRun(files, schedule, now); !next.Equal(time.Date(2026, 10, 20, 2, 0, 0, 0, time.Local)) {
+		t.Errorf("next = %s, want next scheduled run", next)
+	}
+}
@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"time"
+	"yd_backup/internal/models"
+)
+
+// Trash - назначение с корзиной, куда попадают удаленные копии
+type Trash interface {
+	ListTrash() ([]models.TrashFile, error)
+	RestoreTrash(name string) (string, error)
+	RemoveTrash(name string) error
+	PurgeTrash(grace time.Duration) ([]string, error)
+}
+
+// TrashListing - копии назначения в корзине
+type TrashListing struct {
+	Destination string             `json:"destination"`
+	Files       []models.TrashFile `json:"files"`
+	Error       string             `json:"error,omitempty"`
+}
+
+// ListTrash возвращает копии в корзинах назначений с указанными именами, а
+// без имен - во всех назначениях с корзиной
+func (b *BackupService) ListTrash(names []string) ([]TrashListing, Status, error) {
+	destinations, err := b.trashDestinations(names)
+
+	if err != nil {
+		return nil, StatusFailed, err
+	}
+
+	var result []TrashListing
+
+	success := 0
+
+	for _, destination := range destinations {
+		listing := TrashListing{Destination: destination.Name}
+
+		files, err := destination.Remote.(Trash).ListTrash()
+
+		if err != nil {
+			b.logger.With(zap.String("Destination", destination.Name)).With(zap.Error(err)).
+				Error("unable to list trash")
+			listing.Error = err.Error()
+		} else {
+			listing.Files = files
+			success++
+		}
+
+		result = append(result, listing)
+	}
+
+	return result, newStatus(success, len(destinations)), nil
+}
+
+// RestoreTrash возвращает копию name из корзины назначения на прежнее место
+// и снова отмечает ее в каталоге загруженной
+func (b *BackupService) RestoreTrash(destinationName string, name string) (string, error) {
+	destinations, err := b.trashDestinations([]string{destinationName})
+
+	if err != nil {
+		return "", err
+	}
+
+	restored, err := destinations[0].Remote.(Trash).RestoreTrash(name)
+
+	if err != nil {
+		return "", fmt.Errorf("unable to restore %s from trash of %s: %v", name, destinationName, err)
+	}
+
+	if entry, found := b.findEntry(destinationName, restored); found {
+		b.updateCatalog(entry.ID, func(entry *models.CatalogEntry) {
+			entry.Copy(destinationName).Status = models.CopyUploaded
+		})
+	}
+
+	b.logger.With(zap.String("Destination", destinationName)).With(zap.String("Path", restored)).
+		Info("Backup restored from trash")
+
+	return restored, nil
+}
+
+// RemoveTrash безвозвратно удаляет копию name из корзины назначения
+func (b *BackupService) RemoveTrash(destinationName string, name string) error {
+	destinations, err := b.trashDestinations([]string{destinationName})
+
+	if err != nil {
+		return err
+	}
+
+	if err := destinations[0].Remote.(Trash).RemoveTrash(name); err != nil {
+		return fmt.Errorf("unable to remove %s from trash of %s: %v", name, destinationName, err)
+	}
+
+	b.logger.With(zap.String("Destination", destinationName)).With(zap.String("Name", name)).
+		Info("Backup removed from trash")
+
+	return nil
+}
+
+// PurgeTrash удаляет из корзин назначений с указанными именами копии, чей
+// срок trash_grace истек, а при all - все копии назначений
+func (b *BackupService) PurgeTrash(names []string, all bool) ([]string, Status, error) {
+	destinations, err := b.trashDestinations(names)
+
+	if err != nil {
+		return nil, StatusFailed, err
+	}
+
+	var result []string
+
+	success := 0
+
+	for _, destination := range destinations {
+		if !all && destination.TrashGrace.Duration == 0 {
+			success++
+			continue
+		}
+
+		grace := destination.TrashGrace.Duration
+
+		if all {
+			grace = 0
+		}
+
+		paths, err := b.purgeTrash(destination, grace)
+
+		result = append(result, paths...)
+
+		if err == nil {
+			success++
+		}
+	}
+
+	return result, newStatus(success, len(destinations)), nil
+}
+
+// purgeTrash удаляет из корзины назначения копии старше grace
+func (b *BackupService) purgeTrash(destination Destination, grace time.Duration) ([]string, error) {
+	logger := b.logger.With(zap.String("Destination", destination.Name))
+
+	paths, err := destination.Remote.(Trash).PurgeTrash(grace)
+
+	if err != nil {
+		logger.With(zap.Error(err)).Error("unable to purge trash")
+	}
+
+	if len(paths) > 0 {
+		logger.With(zap.Strings("paths", paths)).With(zap.Int("count", len(paths))).Info("Trash purged")
+	}
+
+	return paths, err
+}
+
+// trashDestinations выбирает назначения с корзиной. Без имен выбираются все
+// такие назначения, явно названное назначение без корзины - ошибка
+func (b *BackupService) trashDestinations(names []string) ([]Destination, error) {
+	destinations, err := b.selectDestinations(names)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Destination
+
+	for _, destination := range destinations {
+		if _, ok := destination.Remote.(Trash); ok {
+			result = append(result, destination)
+			continue
+		}
+
+		if len(names) > 0 {
+			return nil, fmt.Errorf("destination %s has no trash", destination.Name)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"time"
+	"yd_backup/internal/models"
+)
+
+const (
+	VerifyOk          = "ok"
+	VerifyMissing     = "missing"
+	VerifySizeInvalid = "size mismatch"
+	VerifyHashInvalid = "md5 mismatch"
+)
+
+// Verification - результат сверки одной копии из каталога с назначением
+type Verification struct {
+	Destination string `json:"destination"`
+	Name        string `json:"name"`
+	Result      string `json:"result"`
+}
+
+// Verify сверяет загруженные копии из каталога с назначениями: наличие,
+// размер и, если назначение его сообщает, md5. Итог сверки каждой записи
+// сохраняется в каталоге
+func (b *BackupService) Verify() ([]Verification, Status) {
+	if b.catalog == nil {
+		b.logger.Error("catalog is not configured")
+		return nil, StatusFailed
+	}
+
+	entries, err := b.catalog.Entries()
+
+	if err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to read catalog")
+		return nil, StatusFailed
+	}
+
+	remoteFiles := b.remoteFiles()
+
+	var result []Verification
+
+	success := 0
+
+	for _, entry := range entries {
+		verified := ""
+
+		for _, catalogCopy := range entry.Copies {
+			remote, ok := remoteFiles[catalogCopy.Destination]
+
+			if !ok || catalogCopy.Status != models.CopyUploaded {
+				continue
+			}
+
+			verification := Verification{
+				Destination: catalogCopy.Destination,
+				Name:        entry.ID,
+				Result:      VerifyOk,
+			}
+
+			remoteFile, ok := remote[catalogCopy.RemoteName()]
+
+			switch {
+			case !ok:
+				verification.Result = VerifyMissing
+			case remoteFile.Size != entry.Size:
+				verification.Result = VerifySizeInvalid
+			case remoteFile.Md5 != "" && entry.Md5 != "" && remoteFile.Md5 != entry.Md5:
+				verification.Result = VerifyHashInvalid
+			}
+
+			if verification.Result == VerifyOk {
+				success++
+			} else {
+				b.logger.With(zap.String("Destination", catalogCopy.Destination)).
+					With(zap.String("Name", entry.ID)).
+					With(zap.String("result", verification.Result)).
+					Error("Backup verification failed")
+			}
+
+			if verified == "" || verified == VerifyOk {
+				verified = verification.Result
+			}
+
+			result = append(result, verification)
+		}
+
+		if verified != "" {
+			b.updateCatalog(entry.ID, func(entry *models.CatalogEntry) {
+				entry.Verified = verified
+				entry.VerifiedAt = time.Now()
+			})
+		}
+	}
+
+	b.publishCatalog()
+
+	if len(remoteFiles) == 0 && len(b.destinations) > 0 {
+		return result, StatusFailed
+	}
+
+	if len(remoteFiles) < len(b.destinations) && success == len(result) {
+		return result, StatusPartial
+	}
+
+	return result, newStatus(success, len(result))
+}
+
+// remoteFiles возвращает копии в назначениях по именам назначений и файлов.
+// Назначения, список которых получить не удалось, в результат не входят
+func (b *BackupService) remoteFiles() map[string]map[string]models.BackupFile {
+	result := make(map[string]map[string]models.BackupFile)
+
+	for _, destination := range b.destinations {
+		files, err := destination.Remote.ListBackup()
+
+		if err != nil {
+			b.logger.With(zap.String("Destination", destination.Name)).With(zap.Error(err)).
+				Error("unable to list backups")
+			continue
+		}
+
+		result[destination.Name] = make(map[string]models.BackupFile)
+
+		for _, file := range files {
+			result[destination.Name][file.Name] = file
+		}
+	}
+
+	return result
+}
+
+func fileMd5(path string) string {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return ""
+	}
+
+	defer file.Close()
+
+	hash := md5.New()
+
+	if _, err := io.Copy(hash, file); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,148 @@
+package usecase
+
+import (
+	"go.uber.org/zap"
+	"time"
+	"yd_backup/internal/models"
+)
+
+// StaleJob - задание, последняя успешная копия которого старше MaxAge.
+// Пустой LastSuccess означает, что задание запускалось, но ни разу не
+// завершилось успешно
+type StaleJob struct {
+	Job         string        `json:"job"`
+	LastSuccess time.Time     `json:"last_success,omitempty"`
+	Age         time.Duration `json:"age"`
+	MaxAge      time.Duration `json:"max_age"`
+}
+
+// CheckStale проверяет возраст последних успешных копий заданий с max_age
+// и уведомляет о новых устаревших заданиях, кроме заданий с политикой never.
+// О задании уведомляют один раз, до его следующей успешной копии
+func (b *BackupService) CheckStale() ([]StaleJob, Status) {
+	started := time.Now()
+
+	var stale, notify []StaleJob
+
+	for _, files := range b.setting.Files {
+		if files.MaxAge.Duration == 0 {
+			continue
+		}
+
+		logger := b.logger.With(zap.String("Job", files.Name))
+
+		var state models.JobState
+
+		if b.state != nil {
+			state, _ = b.state.Get(files.Name)
+		}
+
+		last := state.LastSuccess
+
+		if last.IsZero() {
+			last = b.lastBackup(files.Name)
+		}
+
+		if last.IsZero() && state.LastRun.IsZero() {
+			logger.Warn("Job has never run, backup age is not checked")
+			continue
+		}
+
+		job := StaleJob{Job: files.Name, LastSuccess: last, MaxAge: files.MaxAge.Duration}
+
+		if !last.IsZero() {
+			job.Age = started.Sub(last)
+		}
+
+		fresh := !last.IsZero() && job.Age <= job.MaxAge
+
+		if b.metrics != nil {
+			b.metrics.Set(MetricJobStale, boolValue(!fresh), "job", files.Name)
+		}
+
+		if fresh {
+			continue
+		}
+
+		logger.With(zap.Time("last_success", last)).With(zap.Duration("max_age", job.MaxAge)).
+			Error("Last successful backup is too old")
+
+		stale = append(stale, job)
+
+		if b.jobPolicy(files.Name) == models.NotifyNever {
+			continue
+		}
+
+		if !state.StaleNotified.IsZero() && !state.StaleNotified.Before(last) {
+			continue
+		}
+
+		notify = append(notify, job)
+
+		if b.state == nil {
+			continue
+		}
+
+		state.StaleNotified = started
+
+		if err := b.state.Set(files.Name, state); err != nil {
+			b.logger.With(zap.Error(err)).Error("unable to save job state")
+		}
+	}
+
+	if len(notify) > 0 {
+		b.notify(Report{
+			Kind:     ReportStale,
+			Status:   StatusFailed,
+			Started:  started,
+			Finished: time.Now(),
+			Stale:    notify,
+		})
+	}
+
+	if len(stale) > 0 {
+		return stale, StatusFailed
+	}
+
+	return stale, StatusSuccess
+}
+
+// lastBackup возвращает время самой новой загруженной копии задания по
+// каталогу; им пользуются, пока в состоянии нет времени успешного запуска
+func (b *BackupService) lastBackup(job string) time.Time {
+	var last time.Time
+
+	if b.catalog == nil {
+		return last
+	}
+
+	entries, err := b.catalog.Entries()
+
+	if err != nil {
+		b.logger.With(zap.Error(err)).Error("unable to read catalog")
+		return last
+	}
+
+	for _, entry := range entries {
+		if entry.Job != job || !entry.Created.After(last) {
+			continue
+		}
+
+		for _, catalogCopy := range entry.Copies {
+			if catalogCopy.Status == models.CopyUploaded {
+				last = entry.Created
+				break
+			}
+		}
+	}
+
+	return last
+}
+
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+
+	return 0
+}
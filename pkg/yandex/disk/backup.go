@@ -8,6 +8,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"yd_backup/internal/repo"
@@ -17,24 +18,127 @@ import (
 const yandexDiskURL = "https://cloud-api.yandex.net"
 
 const (
+	diskURL     = "v1/disk"
 	uploadURL   = "v1/disk/resources/upload"
+	downloadURL = "v1/disk/resources/download"
 	resourceURL = "v1/disk/resources"
+	trashURL    = "v1/disk/trash/resources"
+	restoreURL  = "v1/disk/trash/resources/restore"
 )
 
+const maxRedirects = 5
+
+// Observer получает метод и код ответа каждого запроса к API; при сетевой
+// ошибке код равен нулю
+type Observer func(method string, statusCode int)
+
+// TokenRefresher обновляет токен доступа, когда API отвечает 401
+type TokenRefresher interface {
+	Refresh() (string, error)
+}
+
+// YandexDisk - клиент REST API Yandex Disk. URL можно заменить на адрес
+// тестового сервера
 type YandexDisk struct {
-	client  *fasthttp.Client
-	Token   string
-	Timeout time.Duration
+	client    *fasthttp.Client
+	URL       string
+	Token     string
+	Timeout   time.Duration
+	refresher TokenRefresher
+	observer  Observer
+	mu        sync.RWMutex
 }
 
 func (y *YandexDisk) GetToken() string {
+	y.mu.RLock()
+	defer y.mu.RUnlock()
 	return y.Token
 }
 
 func (y *YandexDisk) SetToken(token string) {
+	y.mu.Lock()
+	defer y.mu.Unlock()
 	y.Token = token
 }
 
+func (y *YandexDisk) SetRefresher(refresher TokenRefresher) {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	y.refresher = refresher
+}
+
+func (y *YandexDisk) SetObserver(observer Observer) {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	y.observer = observer
+}
+
+// send выполняет запрос клиентом client и сообщает его итог наблюдателю.
+// Через него идут все запросы, включая загрузки и скачивания по ссылкам
+func (y *YandexDisk) send(client *fasthttp.Client, request *fasthttp.Request, response *fasthttp.Response) error {
+	err := client.Do(request, response)
+
+	y.mu.RLock()
+	observer := y.observer
+	y.mu.RUnlock()
+
+	if observer != nil {
+		statusCode := 0
+
+		if err == nil {
+			statusCode = response.StatusCode()
+		}
+
+		observer(string(request.Header.Method()), statusCode)
+	}
+
+	return err
+}
+
+// do выполняет запрос к API с текущим токеном. При ответе 401 токен
+// обновляется через refresher и запрос повторяется один раз. Если токен
+// уже обновил параллельный запрос, повторный вызов refresher не выполняется
+func (y *YandexDisk) do(request *fasthttp.Request, response *fasthttp.Response) error {
+	token := y.GetToken()
+
+	request.Header.Set("Authorization", fmt.Sprintf("OAuth %s", token))
+
+	if err := y.send(y.client, request, response); err != nil {
+		return err
+	}
+
+	if response.StatusCode() != fasthttp.StatusUnauthorized {
+		return nil
+	}
+
+	y.mu.Lock()
+
+	if y.refresher == nil {
+		y.mu.Unlock()
+		return nil
+	}
+
+	if y.Token == token {
+		refreshed, err := y.refresher.Refresh()
+
+		if err != nil {
+			y.mu.Unlock()
+			return fmt.Errorf("unable to refresh token: %v", err)
+		}
+
+		y.Token = refreshed
+	}
+
+	token = y.Token
+
+	y.mu.Unlock()
+
+	request.Header.Set("Authorization", fmt.Sprintf("OAuth %s", token))
+	response.Reset()
+
+	return y.send(y.client, request, response)
+}
+
 func NewBackupYandex(token string, timeout time.Duration) *YandexDisk {
 	client := &fasthttp.Client{
 		TLSConfig: &tls.Config{
@@ -51,9 +155,48 @@ func NewBackupYandex(token string, timeout time.Duration) *YandexDisk {
 	}
 
 	return &YandexDisk{
-		Token:  token,
-		client: client,
+		URL:     yandexDiskURL,
+		Token:   token,
+		Timeout: timeout,
+		client:  client,
+	}
+}
+
+// GetDisk - данные о диске пользователя: общий и занятый объем, размер корзины
+// Valid status codes: 200 OK
+func (y *YandexDisk) GetDisk() (models.Disk, error) {
+	var result models.Disk
+
+	request := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(request)
+	response := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(response)
+
+	request.SetRequestURI(fmt.Sprintf("%s/%s", y.URL, diskURL))
+	request.Header.SetMethod(fasthttp.MethodGet)
+	request.Header.SetContentType("application/json")
+
+	if err := y.do(request, response); err != nil {
+		return result, err
+	}
+
+	if response.StatusCode() != fasthttp.StatusOK {
+		responseError := &models.ResponseError{}
+
+		if err := json.Unmarshal(response.Body(), responseError); err != nil {
+			return result, err
+		}
+
+		responseError.StatusCode = response.StatusCode()
+
+		return result, responseError
 	}
+
+	if err := json.Unmarshal(response.Body(), &result); err != nil {
+		return result, err
+	}
+
+	return result, nil
 }
 
 func (y *YandexDisk) GetResource(params models.Params) (models.Resource, error) {
@@ -68,10 +211,9 @@ func (y *YandexDisk) GetResource(params models.Params) (models.Resource, error)
 		return result, fmt.Errorf("path is empty")
 	}
 
-	request.SetRequestURI(fmt.Sprintf("%s/%s", yandexDiskURL, resourceURL))
+	request.SetRequestURI(fmt.Sprintf("%s/%s", y.URL, resourceURL))
 	request.Header.SetMethod(fasthttp.MethodGet)
 	request.Header.SetContentType("application/json")
-	request.Header.Set("Authorization", fmt.Sprintf("OAuth %s", y.Token))
 
 	if params.Fields != nil && len(params.Fields) > 0 {
 		request.URI().QueryArgs().Add("fields", strings.Join(params.Fields, ","))
@@ -99,7 +241,165 @@ func (y *YandexDisk) GetResource(params models.Params) (models.Resource, error)
 
 	request.URI().QueryArgs().Add("path", params.Path)
 
-	if err := y.client.Do(request, response); err != nil {
+	if err := y.do(request, response); err != nil {
+		return result, err
+	}
+
+	if response.StatusCode() != fasthttp.StatusOK {
+		responseError := &models.ResponseError{}
+
+		if err := json.Unmarshal(response.Body(), responseError); err != nil {
+			return result, err
+		}
+
+		responseError.StatusCode = response.StatusCode()
+
+		return result, responseError
+	}
+
+	if err := json.Unmarshal(response.Body(), &result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+
+}
+
+// GetTrash - содержимое корзины или ресурса в ней постранично. Путь по
+// умолчанию - корень корзины trash:/
+// Valid status codes: 200 OK
+func (y *YandexDisk) GetTrash(params models.Params) (models.Resource, error) {
+	var result models.Resource
+
+	request := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(request)
+	response := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(response)
+
+	if params.Path == "" {
+		params.Path = "trash:/"
+	}
+
+	request.SetRequestURI(fmt.Sprintf("%s/%s", y.URL, trashURL))
+	request.Header.SetMethod(fasthttp.MethodGet)
+	request.Header.SetContentType("application/json")
+
+	if params.Limit > 0 {
+		request.URI().QueryArgs().Add("limit", strconv.Itoa(params.Limit))
+	}
+
+	if params.Offset > 0 {
+		request.URI().QueryArgs().Add("offset", strconv.Itoa(params.Offset))
+	}
+
+	if params.Sort != "" {
+		request.URI().QueryArgs().Add("sort", params.Sort)
+	}
+
+	request.URI().QueryArgs().Add("path", params.Path)
+
+	if err := y.do(request, response); err != nil {
+		return result, err
+	}
+
+	if response.StatusCode() != fasthttp.StatusOK {
+		responseError := &models.ResponseError{}
+
+		if err := json.Unmarshal(response.Body(), responseError); err != nil {
+			return result, err
+		}
+
+		responseError.StatusCode = response.StatusCode()
+
+		return result, responseError
+	}
+
+	if err := json.Unmarshal(response.Body(), &result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// RemoveTrash - удаление ресурса из корзины. Пустой путь очистил бы всю
+// корзину, поэтому он запрещен
+// Valid status codes: 204 No Content, 202 Accepted
+func (y *YandexDisk) RemoveTrash(params models.Params) (models.Link, error) {
+	var link models.Link
+
+	request := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(request)
+	response := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(response)
+
+	if params.Path == "" || params.Path == "trash:/" {
+		return link, fmt.Errorf("path is empty")
+	}
+
+	request.SetRequestURI(fmt.Sprintf("%s/%s", y.URL, trashURL))
+	request.Header.SetMethod(fasthttp.MethodDelete)
+	request.Header.SetContentType("application/json")
+
+	request.URI().QueryArgs().Add("path", params.Path)
+
+	if err := y.do(request, response); err != nil {
+		return link, err
+	}
+
+	switch response.StatusCode() {
+	case fasthttp.StatusNoContent:
+		return link, nil
+	case fasthttp.StatusAccepted:
+		err := json.Unmarshal(response.Body(), &link)
+
+		return link, err
+	}
+
+	responseError := &models.ResponseError{}
+
+	if err := json.Unmarshal(response.Body(), responseError); err != nil {
+		return link, err
+	}
+
+	responseError.StatusCode = response.StatusCode()
+
+	return link, responseError
+}
+
+// PatchResource - изменение пользовательских свойств ресурса. Свойство со
+// значением nil удаляется
+// ? path=<путь к ресурсу>
+// Valid status codes: 200 OK
+func (y *YandexDisk) PatchResource(params models.Params, properties map[string]interface{}) (models.Resource, error) {
+	var result models.Resource
+
+	request := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(request)
+	response := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(response)
+
+	if params.Path == "" {
+		return result, fmt.Errorf("path is empty")
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"custom_properties": properties})
+
+	if err != nil {
+		return result, err
+	}
+
+	request.SetRequestURI(fmt.Sprintf("%s/%s", y.URL, resourceURL))
+	request.Header.SetMethod(fasthttp.MethodPatch)
+	request.Header.SetContentType("application/json")
+
+	if len(params.Fields) > 0 {
+		request.URI().QueryArgs().Add("fields", strings.Join(params.Fields, ","))
+	}
+
+	request.URI().QueryArgs().Add("path", params.Path)
+	request.SetBody(body)
+
+	if err := y.do(request, response); err != nil {
 		return result, err
 	}
 
@@ -120,7 +420,57 @@ func (y *YandexDisk) GetResource(params models.Params) (models.Resource, error)
 	}
 
 	return result, nil
+}
+
+// RestoreTrash - восстановление ресурса из корзины по исходному пути.
+// Name задает новое имя ресурса, Overwrite - перезапись существующего
+// Valid status codes: 201 Created, 202 Accepted
+func (y *YandexDisk) RestoreTrash(params models.Params) (models.Link, error) {
+	var link models.Link
+
+	request := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(request)
+	response := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(response)
+
+	if params.Path == "" || params.Path == "trash:/" {
+		return link, fmt.Errorf("path is empty")
+	}
+
+	request.SetRequestURI(fmt.Sprintf("%s/%s", y.URL, restoreURL))
+	request.Header.SetMethod(fasthttp.MethodPut)
+	request.Header.SetContentType("application/json")
+
+	request.URI().QueryArgs().Add("path", params.Path)
 
+	if params.Name != "" {
+		request.URI().QueryArgs().Add("name", params.Name)
+	}
+
+	if params.Overwrite {
+		request.URI().QueryArgs().Add("overwrite", strconv.FormatBool(params.Overwrite))
+	}
+
+	if err := y.do(request, response); err != nil {
+		return link, err
+	}
+
+	switch response.StatusCode() {
+	case fasthttp.StatusCreated, fasthttp.StatusAccepted:
+		err := json.Unmarshal(response.Body(), &link)
+
+		return link, err
+	}
+
+	responseError := &models.ResponseError{}
+
+	if err := json.Unmarshal(response.Body(), responseError); err != nil {
+		return link, err
+	}
+
+	responseError.StatusCode = response.StatusCode()
+
+	return link, responseError
 }
 
 func (y *YandexDisk) CreateResource(params models.Params) (models.Link, error) {
@@ -147,10 +497,9 @@ func (y *YandexDisk) CreateResource(params models.Params) (models.Link, error) {
 
 	request.URI().QueryArgs().Add("path", params.Path)
 
-	request.Header.Set("Authorization", fmt.Sprintf("OAuth %s", y.Token))
-	request.SetRequestURI(fmt.Sprintf("%s/%s", yandexDiskURL, resourceURL))
+	request.SetRequestURI(fmt.Sprintf("%s/%s", y.URL, resourceURL))
 
-	if err := y.client.Do(request, response); err != nil {
+	if err := y.do(request, response); err != nil {
 		return link, err
 	}
 
@@ -186,7 +535,7 @@ func (y *YandexDisk) CreateLink(params models.Params) (models.Link, error) {
 	response := fasthttp.AcquireResponse()
 	defer fasthttp.ReleaseResponse(response)
 
-	request.SetRequestURI(fmt.Sprintf("%s/%s", yandexDiskURL, uploadURL))
+	request.SetRequestURI(fmt.Sprintf("%s/%s", y.URL, uploadURL))
 
 	if params.Path == "" {
 		return link, fmt.Errorf("path is empty")
@@ -198,12 +547,11 @@ func (y *YandexDisk) CreateLink(params models.Params) (models.Link, error) {
 
 	request.Header.SetMethod(fasthttp.MethodGet)
 	request.Header.SetContentType("application/json")
-	request.Header.Set("Authorization", "OAuth "+y.Token)
 
 	request.URI().QueryArgs().Add("path", params.Path)
 	request.URI().QueryArgs().Add("overwrite", strconv.FormatBool(params.Overwrite))
 
-	err := y.client.Do(request, response)
+	err := y.do(request, response)
 
 	if err != nil {
 		return link, err
@@ -238,7 +586,7 @@ func (y *YandexDisk) UploadFile(link models.Link, path string) error {
 	response := fasthttp.AcquireResponse()
 	defer fasthttp.ReleaseResponse(response)
 
-	request.Header.Set("Authorization", fmt.Sprintf("OAuth %s", y.Token))
+	request.Header.Set("Authorization", fmt.Sprintf("OAuth %s", y.GetToken()))
 
 	request.SetRequestURI(link.Href)
 	request.Header.SetMethod(link.Method)
@@ -264,7 +612,7 @@ func (y *YandexDisk) UploadFile(link models.Link, path string) error {
 		WriteTimeout: y.Timeout,
 	}
 
-	err = client.Do(request, response)
+	err = y.send(&client, request, response)
 
 	if err != nil {
 		return err
@@ -293,7 +641,7 @@ func (y *YandexDisk) RemoveResource(params models.Params) (models.Link, error) {
 	defer fasthttp.ReleaseRequest(request)
 	defer fasthttp.ReleaseResponse(response)
 
-	request.SetRequestURI(fmt.Sprintf("%s/%s", yandexDiskURL, resourceURL))
+	request.SetRequestURI(fmt.Sprintf("%s/%s", y.URL, resourceURL))
 
 	request.Header.SetMethod(fasthttp.MethodDelete)
 	request.Header.SetContentType("application/json")
@@ -305,9 +653,7 @@ func (y *YandexDisk) RemoveResource(params models.Params) (models.Link, error) {
 	request.URI().QueryArgs().Add("path", params.Path)
 	request.URI().QueryArgs().Add("permanently", strconv.FormatBool(params.Permanently))
 
-	request.Header.Set("Authorization", fmt.Sprintf("OAuth %s", y.Token))
-
-	err := y.client.Do(request, response)
+	err := y.do(request, response)
 
 	if err != nil {
 		return link, err
@@ -336,3 +682,172 @@ func (y *YandexDisk) RemoveResource(params models.Params) (models.Link, error) {
 
 	return link, errResp
 }
+
+// DownloadLink - получение ссылки на скачивание файла
+// ? path=<путь к скачиваемому файлу>
+// Valid status codes: 200 OK
+func (y *YandexDisk) DownloadLink(params models.Params) (models.Link, error) {
+	var link models.Link
+
+	request := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(request)
+	response := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(response)
+
+	if params.Path == "" {
+		return link, fmt.Errorf("path is empty")
+	}
+
+	request.SetRequestURI(fmt.Sprintf("%s/%s", y.URL, downloadURL))
+	request.Header.SetMethod(fasthttp.MethodGet)
+	request.Header.SetContentType("application/json")
+
+	request.URI().QueryArgs().Add("path", params.Path)
+
+	if err := y.do(request, response); err != nil {
+		return link, err
+	}
+
+	if response.StatusCode() != fasthttp.StatusOK {
+		responseError := &models.ResponseError{}
+
+		if err := json.Unmarshal(response.Body(), responseError); err != nil {
+			return link, err
+		}
+
+		responseError.StatusCode = response.StatusCode()
+
+		return link, responseError
+	}
+
+	if err := json.Unmarshal(response.Body(), &link); err != nil {
+		return link, err
+	}
+
+	return link, nil
+}
+
+// DownloadFile скачивает файл по ссылке из DownloadLink потоком, без
+// загрузки в память, и атомарно записывает его в path. Сервер скачивания
+// может перенаправить запрос, поэтому редиректы обрабатываются вручную.
+// Токен отправляется только в первом запросе: хост редиректа его не получает
+func (y *YandexDisk) DownloadFile(link models.Link, path string) error {
+	client := &fasthttp.Client{
+		ReadTimeout:        y.Timeout,
+		WriteTimeout:       y.Timeout,
+		StreamResponseBody: true,
+	}
+
+	href := link.Href
+
+	for redirects := 0; ; redirects++ {
+		location, err := y.downloadFile(client, href, path, redirects == 0, redirects < maxRedirects)
+
+		if err != nil || location == "" {
+			return err
+		}
+
+		href = location
+	}
+}
+
+func (y *YandexDisk) downloadFile(client *fasthttp.Client, href string, path string, authorize bool, redirect bool) (string, error) {
+	request := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(request)
+	response := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(response)
+
+	request.SetRequestURI(href)
+	request.Header.SetMethod(fasthttp.MethodGet)
+
+	if authorize {
+		request.Header.Set("Authorization", fmt.Sprintf("OAuth %s", y.GetToken()))
+	}
+
+	if err := y.send(client, request, response); err != nil {
+		return "", err
+	}
+
+	if redirect && fasthttp.StatusCodeIsRedirect(response.StatusCode()) {
+		uri := request.URI()
+		uri.Update(string(response.Header.Peek(fasthttp.HeaderLocation)))
+
+		return uri.String(), nil
+	}
+
+	if response.StatusCode() != fasthttp.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d", response.StatusCode())
+	}
+
+	return "", repo.AtomicWrite(path, response.BodyStream())
+}
+
+// UploadData загружает небольшой файл из памяти по ссылке из CreateLink
+func (y *YandexDisk) UploadData(link models.Link, data []byte) error {
+	request := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(request)
+	response := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(response)
+
+	request.SetRequestURI(link.Href)
+	request.Header.SetMethod(link.Method)
+	request.Header.Set("Authorization", fmt.Sprintf("OAuth %s", y.GetToken()))
+	request.SetBody(data)
+
+	if err := y.send(y.client, request, response); err != nil {
+		return err
+	}
+
+	if response.StatusCode() != fasthttp.StatusCreated && response.StatusCode() != fasthttp.StatusAccepted {
+		return fmt.Errorf("unexpected status code %d", response.StatusCode())
+	}
+
+	return nil
+}
+
+// DownloadData скачивает небольшой файл в память по ссылке из DownloadLink.
+// Как и в DownloadFile, токен не отправляется на хосты редиректов
+func (y *YandexDisk) DownloadData(link models.Link) ([]byte, error) {
+	href := link.Href
+
+	for redirects := 0; ; redirects++ {
+		location, data, err := y.downloadData(href, redirects == 0, redirects < maxRedirects)
+
+		if err != nil || location == "" {
+			return data, err
+		}
+
+		href = location
+	}
+}
+
+func (y *YandexDisk) downloadData(href string, authorize bool, redirect bool) (string, []byte, error) {
+	request := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(request)
+	response := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(response)
+
+	request.SetRequestURI(href)
+	request.Header.SetMethod(fasthttp.MethodGet)
+
+	if authorize {
+		request.Header.Set("Authorization", fmt.Sprintf("OAuth %s", y.GetToken()))
+	}
+
+	if err := y.send(y.client, request, response); err != nil {
+		return "", nil, err
+	}
+
+	if redirect && fasthttp.StatusCodeIsRedirect(response.StatusCode()) {
+		uri := request.URI()
+		uri.Update(string(response.Header.Peek(fasthttp.HeaderLocation)))
+
+		return uri.String(), nil, nil
+	}
+
+	if response.StatusCode() != fasthttp.StatusOK {
+		return "", nil, fmt.Errorf("unexpected status code %d", response.StatusCode())
+	}
+
+	return "", append([]byte(nil), response.Body()...), nil
+}
@@ -0,0 +1,287 @@
+package disk
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"yd_backup/pkg/yandex/disk/models"
+)
+
+// headerRecorder запоминает заголовок Authorization каждого запроса
+type headerRecorder struct {
+	mu      sync.Mutex
+	headers []string
+}
+
+func (r *headerRecorder) record(request *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.headers = append(r.headers, request.Header.Get("Authorization"))
+}
+
+func (r *headerRecorder) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.headers...)
+}
+
+func TestDownloadRedirectWithoutToken(t *testing.T) {
+	storage := &headerRecorder{}
+
+	storageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		storage.record(r)
+		w.Write([]byte("backup"))
+	}))
+	t.Cleanup(storageServer.Close)
+
+	downloader := &headerRecorder{}
+
+	downloadServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloader.record(r)
+		http.Redirect(w, r, storageServer.URL+"/storage/file", http.StatusFound)
+	}))
+	t.Cleanup(downloadServer.Close)
+
+	yandex := NewBackupYandex("secret", 5*time.Second)
+	link := models.Link{Href: downloadServer.URL + "/download/file", Method: http.MethodGet}
+
+	path := filepath.Join(t.TempDir(), "file")
+
+	if err := yandex.DownloadFile(link, path); err != nil {
+		t.Fatalf("DownloadFile: %v", err)
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "backup" {
+		t.Errorf("file = %q, want body of the redirect target", data)
+	}
+
+	data, err := yandex.DownloadData(link)
+
+	if err != nil || string(data) != "backup" {
+		t.Fatalf("DownloadData = %q, %v, want body of the redirect target", data, err)
+	}
+
+	for _, header := range downloader.recorded() {
+		if header != "OAuth secret" {
+			t.Errorf("first request Authorization = %q, want token", header)
+		}
+	}
+
+	headers := storage.recorded()
+
+	if len(headers) != 2 {
+		t.Fatalf("redirect requests = %d, want 2", len(headers))
+	}
+
+	for _, header := range headers {
+		if header != "" {
+			t.Errorf("redirect Authorization = %q, want none", header)
+		}
+	}
+}
+
+// countRefresher выдает новый токен и считает вызовы
+type countRefresher struct {
+	mu    sync.Mutex
+	token string
+	err   error
+	calls int
+}
+
+func (r *countRefresher) Refresh() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls++
+
+	return r.token, r.err
+}
+
+// newTestDisk - клиент API, который принимает только токен valid
+func newTestDisk(t *testing.T, token string) (*YandexDisk, *headerRecorder) {
+	t.Helper()
+
+	recorder := &headerRecorder{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder.record(r)
+
+		if r.Header.Get("Authorization") != "OAuth valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "UnauthorizedError", "description": "Unauthorized"}`))
+			return
+		}
+
+		w.Write([]byte(`{"total_space": 100, "used_space": 40}`))
+	}))
+	t.Cleanup(server.Close)
+
+	yandex := NewBackupYandex(token, 5*time.Second)
+	yandex.URL = server.URL
+
+	return yandex, recorder
+}
+
+func TestRefreshOnUnauthorized(t *testing.T) {
+	yandex, recorder := newTestDisk(t, "expired")
+
+	refresher := &countRefresher{token: "valid"}
+	yandex.SetRefresher(refresher)
+
+	var statuses []int
+
+	yandex.SetObserver(func(method string, statusCode int) {
+		statuses = append(statuses, statusCode)
+	})
+
+	disk, err := yandex.GetDisk()
+
+	if err != nil {
+		t.Fatalf("GetDisk: %v", err)
+	}
+
+	if disk.TotalSpace != 100 || disk.UsedSpace != 40 {
+		t.Errorf("disk = %+v, want response of the retried request", disk)
+	}
+
+	if refresher.calls != 1 || yandex.GetToken() != "valid" {
+		t.Errorf("refresh calls = %d, token = %q, want one refresh to valid", refresher.calls, yandex.GetToken())
+	}
+
+	if headers := recorder.recorded(); len(headers) != 2 || headers[0] != "OAuth expired" || headers[1] != "OAuth valid" {
+		t.Errorf("requests = %v, want retry with the new token", headers)
+	}
+
+	// Оба запроса учтены наблюдателем
+	if len(statuses) != 2 || statuses[0] != http.StatusUnauthorized || statuses[1] != http.StatusOK {
+		t.Errorf("observed = %v, want 401 and 200", statuses)
+	}
+
+	// Следующие запросы идут с новым токеном без обновления
+	if _, err := yandex.GetDisk(); err != nil || refresher.calls != 1 {
+		t.Errorf("GetDisk = %v, refresh calls = %d, want no refresh", err, refresher.calls)
+	}
+}
+
+func TestRefreshFailed(t *testing.T) {
+	yandex, recorder := newTestDisk(t, "expired")
+
+	refresher := &countRefresher{err: errors.New("invalid_grant")}
+	yandex.SetRefresher(refresher)
+
+	_, err := yandex.GetDisk()
+
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("err = %v, want refresh error", err)
+	}
+
+	if headers := recorder.recorded(); len(headers) != 1 {
+		t.Errorf("requests = %d, want no retry without a new token", len(headers))
+	}
+}
+
+func TestUnauthorizedWithoutRefresher(t *testing.T) {
+	yandex, recorder := newTestDisk(t, "expired")
+
+	_, err := yandex.GetDisk()
+
+	var responseError *models.ResponseError
+
+	if !errors.As(err, &responseError) || responseError.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("err = %v, want 401 response error", err)
+	}
+
+	if headers := recorder.recorded(); len(headers) != 1 {
+		t.Errorf("requests = %d, want 1", len(headers))
+	}
+}
+
+func TestRefreshConcurrent(t *testing.T) {
+	yandex, _ := newTestDisk(t, "expired")
+
+	refresher := &countRefresher{token: "valid"}
+	yandex.SetRefresher(refresher)
+
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := yandex.GetDisk(); err != nil {
+				t.Errorf("GetDisk: %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// Параллельные запросы с отклоненным токеном обновляют его один раз
+	if refresher.calls != 1 {
+		t.Errorf("refresh calls = %d, want 1", refresher.calls)
+	}
+}
+
+func TestUploadObserved(t *testing.T) {
+	statuses := []int{http.StatusCreated, http.StatusInternalServerError, http.StatusCreated}
+
+	var mu sync.Mutex
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		status := statuses[0]
+		statuses = statuses[1:]
+		mu.Unlock()
+
+		w.WriteHeader(status)
+
+		if status != http.StatusCreated {
+			w.Write([]byte(`{"error": "InternalServerError", "description": "upload failed"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	yandex := NewBackupYandex("secret", 5*time.Second)
+
+	var observed []string
+
+	yandex.SetObserver(func(method string, statusCode int) {
+		observed = append(observed, method+" "+http.StatusText(statusCode))
+	})
+
+	path := filepath.Join(t.TempDir(), "backup.zip")
+
+	if err := os.WriteFile(path, []byte("backup"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	link := models.Link{Href: server.URL + "/upload", Method: http.MethodPut}
+
+	if err := yandex.UploadFile(link, path); err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+
+	if err := yandex.UploadFile(link, path); err == nil {
+		t.Fatalf("UploadFile succeeded on 500")
+	}
+
+	if err := yandex.UploadData(link, []byte("catalog")); err != nil {
+		t.Fatalf("UploadData: %v", err)
+	}
+
+	want := []string{"PUT Created", "PUT Internal Server Error", "PUT Created"}
+
+	if strings.Join(observed, ",") != strings.Join(want, ",") {
+		t.Errorf("observed = %v, want %v", observed, want)
+	}
+}
@@ -2,6 +2,7 @@ package models
 
 type Params struct {
 	Path        string   `json:"path"`
+	Name        string   `json:"name"`
 	Overwrite   bool     `json:"overwrite"`
 	Fields      []string `json:"fields"`
 	Limit       int      `json:"limit"`
@@ -10,6 +10,7 @@ type Resource struct {
 	CustomProperties interface{}  `json:"custom_properties"`
 	PublicUrl        string       `json:"public_url"`
 	OriginPath       string       `json:"origin_path"`
+	Deleted          time.Time    `json:"deleted"`
 	Modified         time.Time    `json:"modified"`
 	Path             string       `json:"path"`
 	Md5              string       `json:"md5"`
@@ -0,0 +1,174 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/valyala/fasthttp"
+	"time"
+
+	"yd_backup/pkg/yandex/oauth/models"
+)
+
+const yandexOAuthURL = "https://oauth.yandex.ru"
+
+const (
+	deviceCodeURL = "device/code"
+	tokenURL      = "token"
+)
+
+// Client - клиент Yandex OAuth для входа по коду устройства и обновления
+// токенов. URL можно заменить на адрес тестового сервера
+type Client struct {
+	client       *fasthttp.Client
+	URL          string
+	ClientID     string
+	ClientSecret string
+}
+
+func NewClient(clientID string, clientSecret string) *Client {
+	return &Client{
+		client: &fasthttp.Client{
+			ReadTimeout:  30 * time.Second,
+			WriteTimeout: 30 * time.Second,
+		},
+		URL:          yandexOAuthURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+	}
+}
+
+// DeviceCode запрашивает код устройства и код, который пользователь должен
+// ввести на странице VerificationUrl
+func (c *Client) DeviceCode() (models.DeviceCode, error) {
+	var code models.DeviceCode
+
+	if c.ClientID == "" {
+		return code, fmt.Errorf("client id is empty")
+	}
+
+	args := fasthttp.AcquireArgs()
+	defer fasthttp.ReleaseArgs(args)
+
+	args.Add("client_id", c.ClientID)
+
+	if err := c.post(deviceCodeURL, args, &code); err != nil {
+		return code, err
+	}
+
+	return code, nil
+}
+
+// Token обменивает код устройства на токены. Пока пользователь не подтвердил
+// вход, возвращает ResponseError с типом authorization_pending
+func (c *Client) Token(deviceCode string) (models.Token, error) {
+	args := fasthttp.AcquireArgs()
+	defer fasthttp.ReleaseArgs(args)
+
+	args.Add("grant_type", "device_code")
+	args.Add("code", deviceCode)
+
+	return c.token(args)
+}
+
+// PollToken опрашивает сервер с интервалом из кода устройства, пока
+// пользователь не подтвердит вход или код не истечет
+func (c *Client) PollToken(code models.DeviceCode) (models.Token, error) {
+	interval := time.Duration(code.Interval) * time.Second
+
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+
+	for {
+		token, err := c.Token(code.DeviceCode)
+
+		if err == nil {
+			return token, nil
+		}
+
+		var responseError *models.ResponseError
+
+		if !errors.As(err, &responseError) {
+			return token, err
+		}
+
+		switch responseError.ErrorType {
+		case models.ErrorAuthorizationPending:
+		case models.ErrorSlowDown:
+			interval += 5 * time.Second
+		default:
+			return token, err
+		}
+
+		if code.ExpiresIn > 0 && time.Now().Add(interval).After(deadline) {
+			return token, fmt.Errorf("device code expired")
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// Refresh получает новую пару токенов по refresh-токену
+func (c *Client) Refresh(refreshToken string) (models.Token, error) {
+	if refreshToken == "" {
+		return models.Token{}, fmt.Errorf("refresh token is empty")
+	}
+
+	args := fasthttp.AcquireArgs()
+	defer fasthttp.ReleaseArgs(args)
+
+	args.Add("grant_type", "refresh_token")
+	args.Add("refresh_token", refreshToken)
+
+	return c.token(args)
+}
+
+func (c *Client) token(args *fasthttp.Args) (models.Token, error) {
+	var token models.Token
+
+	args.Add("client_id", c.ClientID)
+	args.Add("client_secret", c.ClientSecret)
+
+	if err := c.post(tokenURL, args, &token); err != nil {
+		return token, err
+	}
+
+	if token.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
+
+func (c *Client) post(url string, args *fasthttp.Args, result interface{}) error {
+	request := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(request)
+	response := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(response)
+
+	request.SetRequestURI(fmt.Sprintf("%s/%s", c.URL, url))
+	request.Header.SetMethod(fasthttp.MethodPost)
+	request.Header.SetContentType("application/x-www-form-urlencoded")
+	request.SetBody(args.QueryString())
+
+	if err := c.client.Do(request, response); err != nil {
+		return err
+	}
+
+	if response.StatusCode() != fasthttp.StatusOK {
+		responseError := &models.ResponseError{}
+
+		if err := json.Unmarshal(response.Body(), responseError); err != nil {
+			return fmt.Errorf("unexpected status code %d", response.StatusCode())
+		}
+
+		responseError.StatusCode = response.StatusCode()
+
+		return responseError
+	}
+
+	return json.Unmarshal(response.Body(), result)
+}
@@ -0,0 +1,171 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"yd_backup/pkg/yandex/oauth/models"
+)
+
+// fakeServer - Yandex OAuth для входа по коду устройства. Первые pending
+// запросов токена получают authorization_pending, затем выдается токен или
+// ошибка denied
+type fakeServer struct {
+	mu      sync.Mutex
+	pending int
+	denied  string
+	forms   []map[string]string
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	form := make(map[string]string)
+
+	for key := range r.PostForm {
+		form[key] = r.PostForm.Get(key)
+	}
+
+	s.mu.Lock()
+	s.forms = append(s.forms, form)
+
+	pending := s.pending > 0
+
+	if pending && r.URL.Path == "/token" {
+		s.pending--
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case r.URL.Path == "/device/code":
+		w.Write([]byte(`{"device_code": "dev", "user_code": "ABCD-1234", "verification_url": "https://ya.ru/device", "interval": 1, "expires_in": 300}`))
+	case r.URL.Path != "/token":
+		http.NotFound(w, r)
+	case pending:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "authorization_pending", "error_description": "User has not yet authorized"}`))
+	case s.denied != "":
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "` + s.denied + `", "error_description": "denied by user"}`))
+	default:
+		w.Write([]byte(`{"access_token": "access", "refresh_token": "refresh", "token_type": "bearer", "expires_in": 3600}`))
+	}
+}
+
+func (s *fakeServer) requests() []map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]map[string]string(nil), s.forms...)
+}
+
+func newTestClient(t *testing.T, server *fakeServer) *Client {
+	t.Helper()
+
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	client := NewClient("client", "secret")
+	client.URL = httpServer.URL
+
+	return client
+}
+
+func TestDeviceFlow(t *testing.T) {
+	server := &fakeServer{pending: 1}
+	client := newTestClient(t, server)
+
+	code, err := client.DeviceCode()
+
+	if err != nil {
+		t.Fatalf("DeviceCode: %v", err)
+	}
+
+	if code.DeviceCode != "dev" || code.UserCode != "ABCD-1234" || code.Interval != 1 {
+		t.Errorf("code = %+v, want device code of the server", code)
+	}
+
+	started := time.Now()
+
+	token, err := client.PollToken(code)
+
+	if err != nil {
+		t.Fatalf("PollToken: %v", err)
+	}
+
+	if token.AccessToken != "access" || token.RefreshToken != "refresh" {
+		t.Errorf("token = %+v, want issued token", token)
+	}
+
+	if remaining := time.Until(token.ExpiresAt); remaining <= 59*time.Minute || remaining > time.Hour {
+		t.Errorf("expires at = %s, want in an hour", token.ExpiresAt)
+	}
+
+	// Повторный запрос после authorization_pending выполняется через interval
+	if elapsed := time.Since(started); elapsed < time.Second {
+		t.Errorf("poll took %s, want to wait interval", elapsed)
+	}
+
+	requests := server.requests()
+
+	if len(requests) != 3 {
+		t.Fatalf("requests = %d, want device code and two token requests", len(requests))
+	}
+
+	if requests[0]["client_id"] != "client" {
+		t.Errorf("device code form = %v, want client_id", requests[0])
+	}
+
+	for _, form := range requests[1:] {
+		if form["grant_type"] != "device_code" || form["code"] != "dev" ||
+			form["client_id"] != "client" || form["client_secret"] != "secret" {
+
+			t.Errorf("token form = %v, want device_code grant with client credentials", form)
+		}
+	}
+}
+
+func TestDeviceFlowDenied(t *testing.T) {
+	server := &fakeServer{denied: "access_denied"}
+	client := newTestClient(t, server)
+
+	_, err := client.PollToken(models.DeviceCode{DeviceCode: "dev", Interval: 1, ExpiresIn: 300})
+
+	var responseError *models.ResponseError
+
+	if !errors.As(err, &responseError) || responseError.ErrorType != "access_denied" ||
+		responseError.StatusCode != http.StatusBadRequest {
+
+		t.Fatalf("err = %v, want access_denied", err)
+	}
+
+	if requests := server.requests(); len(requests) != 1 {
+		t.Errorf("requests = %d, want no polling after denial", len(requests))
+	}
+}
+
+func TestDeviceCodeExpired(t *testing.T) {
+	server := &fakeServer{pending: 10}
+	client := newTestClient(t, server)
+
+	_, err := client.PollToken(models.DeviceCode{DeviceCode: "dev", Interval: 1, ExpiresIn: 1})
+
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("err = %v, want expired device code", err)
+	}
+}
+
+func TestDeviceCodeWithoutClientID(t *testing.T) {
+	if _, err := NewClient("", "").DeviceCode(); err == nil {
+		t.Errorf("DeviceCode without client id succeeded")
+	}
+}
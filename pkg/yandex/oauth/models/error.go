@@ -0,0 +1,20 @@
+package models
+
+const (
+	ErrorAuthorizationPending = "authorization_pending"
+	ErrorSlowDown             = "slow_down"
+)
+
+type ResponseError struct {
+	ErrorType   string `json:"error"`
+	Description string `json:"error_description"`
+	StatusCode  int
+}
+
+func (e *ResponseError) Error() string {
+	if e.Description != "" {
+		return e.ErrorType + ": " + e.Description
+	}
+
+	return e.ErrorType
+}